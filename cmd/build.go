@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"runtime"
+	"strings"
 
 	"github.com/imburbank/terradim/model"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// onlyTerms and excludeTerms hold the dim=value,... terms of the
+// --only and --exclude flags
+var onlyTerms, excludeTerms []string
+
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
 	Use:   "build",
@@ -15,17 +21,33 @@ var buildCmd = &cobra.Command{
 	Long: `Build a terradim model from the src directory and write
 	with resolved configs to the dst directory. For example:
 ...
-WARNING: This command will replace the contents of the dst directory.`,
-	Run: func(cmd *cobra.Command, args []string) {
+Paths written by a previous build that are no longer generated are
+deleted. Files placed in dst by hand are left alone.
+
+With --only or --exclude only the selected enum combinations are built,
+ex. --only dim1=dev,qa --only dim2=ok. Paths of every other combination
+are left untouched.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		src := viper.GetString("src")
 		dst := viper.GetString("dst")
+		if viper.GetBool("dry-run") {
+			return runPlan(src, dst)
+		}
 		fmt.Printf("Building from %s to %s\n", src, dst)
 
-		t, buildConfig := buildModel(src, dst)
+		t, buildConfig, err := buildModel(src, dst)
+		if err != nil {
+			return err
+		}
 		if err := writeToFile(t, buildConfig); err != nil {
-			panic(err)
+			return err
 		}
 		fmt.Println("Build Complete")
+		if viper.GetBool("watch") {
+			return runWatch(src, dst)
+		}
+		return nil
 	},
 }
 
@@ -48,19 +70,62 @@ func init() {
 	buildCmd.Flags().StringP("dst", "d", "terraform/live", "Path to build output")
 	viper.BindPFlag("dst", buildCmd.Flags().Lookup("dst"))
 
+	buildCmd.Flags().String("root-config", "", "Path to root config declaring dimensions")
+	viper.BindPFlag("root-config", buildCmd.Flags().Lookup("root-config"))
+
+	buildCmd.Flags().BoolP("keep-going", "k", false, "Continue past failures and report all of them")
+	viper.BindPFlag("keep-going", buildCmd.Flags().Lookup("keep-going"))
+
+	buildCmd.Flags().Bool("no-prune", false, "Report paths no longer generated instead of deleting them")
+	viper.BindPFlag("no-prune", buildCmd.Flags().Lookup("no-prune"))
+
+	buildCmd.Flags().StringArrayVar(&onlyTerms, "only", nil, "Only build dim=value,... combinations, repeatable")
+	buildCmd.Flags().StringArrayVar(&excludeTerms, "exclude", nil, "Skip dim=value,... combinations, repeatable")
+
+	buildCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of enum combinations to render concurrently")
+	viper.BindPFlag("jobs", buildCmd.Flags().Lookup("jobs"))
+
+	buildCmd.Flags().Bool("dry-run", false, "Show changes as plan does without writing")
+	viper.BindPFlag("dry-run", buildCmd.Flags().Lookup("dry-run"))
+
+	buildCmd.Flags().BoolP("watch", "w", false, "Keep running and rebuild the combinations affected by each change to src")
+	viper.BindPFlag("watch", buildCmd.Flags().Lookup("watch"))
+
 	buildCmd.Flags().BoolP("verbose", "v", false, "Verbose output to stdout")
 	viper.BindPFlag("verbose", buildCmd.Flags().Lookup("verbose"))
 }
 
-func buildModel(src, dst string) (*model.Tree, *model.BuildConfig) {
-	if src[:2] == "./" {
+func buildModel(src, dst string) (*model.Tree, *model.BuildConfig, error) {
+	t, buildConfig, err := model.Create(srcPath(src), dst, viper.GetString("root-config"))
+	if err != nil {
+		return nil, nil, err
+	}
+	buildConfig.Jobs = viper.GetInt("jobs")
+	buildConfig.KeepGoing = viper.GetBool("keep-going")
+	buildConfig.KeepOrphans = viper.GetBool("no-prune")
+	if buildConfig.Filter, err = buildConfig.ParseFilter(onlyTerms, excludeTerms); err != nil {
+		return nil, nil, err
+	}
+	return t, buildConfig, nil
+}
+
+// srcPath returns src as the model stores it
+func srcPath(src string) string {
+	if strings.HasPrefix(src, "./") {
 		src = src[2:]
 	}
-	t, buildConfig := model.Create(src, dst)
-	return t, buildConfig
+	return src
 }
 
 func writeToFile(t *model.Tree, config *model.BuildConfig) error {
-	err := model.Write(t, config)
+	result, err := model.Write(t, config)
+	for _, orphan := range result.Orphans {
+		fmt.Printf("Orphan: %s\n", orphan)
+	}
+	if viper.GetBool("verbose") == true {
+		for _, pruned := range result.Pruned {
+			fmt.Printf("Prune: %s\n", pruned)
+		}
+	}
 	return err
 }
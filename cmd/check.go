@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/imburbank/terradim/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// checkExitDrift is the exit status when dst has drifted from the templates
+const checkExitDrift = 2
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Detect drift between templates and the live layout",
+	Long: `Build a terradim model from the src directory in memory and compare
+it to the dst directory by file type, permissions and contents, as plan
+does. Every path under dst that is added, removed or modified compared
+to a fresh build is listed, with a diff of each file whose contents
+differ. With --only or --exclude only the selected
+combinations are compared. Nothing is written.
+
+Exits 0 when dst matches the templates and 2 when it has drifted.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCheck(viper.GetString("src"), viper.GetString("dst"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().AddFlagSet(buildCmd.Flags())
+	checkCmd.Flags().Lookup("dry-run").Hidden = true
+	checkCmd.Flags().Lookup("no-prune").Hidden = true
+	checkCmd.Flags().Lookup("watch").Hidden = true
+}
+
+func runCheck(src, dst string) error {
+	fmt.Printf("Checking %s against %s\n", dst, src)
+
+	t, buildConfig, err := buildModel(src, dst)
+	if err != nil {
+		return err
+	}
+	outputs, err := model.Render(t, buildConfig)
+	if err != nil {
+		return err
+	}
+	drift, err := model.Check(outputs, dst, buildConfig.Filter)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		fmt.Println("No drift. Live layout matches templates.")
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, change := range drift {
+		counts[change.Action]++
+		fmt.Printf("  %-8s %s\n", change.Action, change.Path)
+	}
+	for _, change := range drift {
+		if change.Diff != "" {
+			fmt.Printf("\n%s", change.Diff)
+		}
+	}
+	fmt.Printf("\nDrift: %d added, %d removed, %d modified.\n",
+		counts[model.DriftAdded], counts[model.DriftRemoved], counts[model.DriftModified])
+	return exitCode(checkExitDrift)
+}
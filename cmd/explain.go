@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain dim=value... [key.path]",
+	Short: "Show where the values of a merged config came from",
+	Long: `Build a terradim model from the src directory in memory and explain
+the config outfile of one combination, ex. explain dim1=dev dim2=ok. Every
+outer dimension of the deepest one named needs a value. The fragments
+merged into the outfile are listed in merge order, least specific first,
+followed by each key with its final value, the fragment that set it and
+the values it overrode. A trailing key path, ex. args.region, limits the
+keys to that path and the keys below it. Dots in keys are escaped with a
+backslash, ex. args.batch\.size. Nothing is written.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExplain(viper.GetString("src"), viper.GetString("dst"), args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+
+	explainCmd.Flags().AddFlagSet(buildCmd.Flags())
+	for _, name := range []string{"dry-run", "no-prune", "watch", "only", "exclude", "keep-going"} {
+		explainCmd.Flags().Lookup(name).Hidden = true
+	}
+}
+
+func runExplain(src, dst string, args []string) error {
+	key := ""
+	if last := args[len(args)-1]; strings.Contains(last, "=") == false {
+		key = last
+		args = args[:len(args)-1]
+	}
+
+	t, buildConfig, err := buildModel(src, dst)
+	if err != nil {
+		return err
+	}
+	explanation, err := buildConfig.Explain(t, args, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(explanation.Dst)
+	fmt.Println("\nFragments, in merge order:")
+	for i, fragment := range explanation.Fragments {
+		fmt.Printf("  %d. %s\n", i+1, fragment)
+	}
+	fmt.Println()
+	for _, explained := range explanation.Keys {
+		fmt.Printf("%s = %s\n", explained.Key, explained.Value)
+		fmt.Printf("  set by    %s\n", explained.Source)
+		for _, overridden := range explained.Overridden {
+			if overridden.Deleted {
+				fmt.Printf("  deleted   in %s\n", overridden.Source)
+				continue
+			}
+			fmt.Printf("  overrode  %s from %s\n", overridden.Value, overridden.Source)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/imburbank/terradim/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// lintExitErrors is the exit status when lint finds errors
+const lintExitErrors = 2
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Report structure problems in the template tree",
+	Long: `Load the terradim model from the src directory and report every
+problem found in its structure, with a severity and the file it is in:
+descriptors without enum values or outfile, empty or duplicate enum
+values, enum dirs without a <dim>_config dir, config dirs and fragments
+no combination reads, fragments found in more than one format and
+invalid matrix entries or schemas. Nothing is written.
+
+Exits 0 when no errors are found and 2 otherwise. Warnings alone do not
+change the exit status.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLint(viper.GetString("src"), viper.GetString("dst"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().AddFlagSet(buildCmd.Flags())
+	for _, name := range []string{"dry-run", "no-prune", "watch", "only", "exclude", "keep-going", "jobs"} {
+		lintCmd.Flags().Lookup(name).Hidden = true
+	}
+}
+
+func runLint(src, dst string) error {
+	t, buildConfig, err := model.Load(srcPath(src), dst, viper.GetString("root-config"))
+	if err != nil {
+		return err
+	}
+	issues := model.Lint(t, buildConfig)
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Severity]++
+		fmt.Println(issue)
+	}
+	fmt.Printf("Lint: %d errors, %d warnings.\n", counts[model.SeverityError], counts[model.SeverityWarning])
+	if counts[model.SeverityError] > 0 {
+		return exitCode(lintExitErrors)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/imburbank/terradim/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// planExitChanges is the exit status when the plan has pending changes
+const planExitChanges = 2
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes a build would make to the live layout",
+	Long: `Build a terradim model from the src directory in memory and list
+every path under the dst directory that a build would create, modify or
+delete, including the manifest and permission changes, with a diff of
+each changed config outfile. Nothing is written.
+
+Exits 0 when dst is up to date and 2 when changes are pending.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlan(viper.GetString("src"), viper.GetString("dst"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().AddFlagSet(buildCmd.Flags())
+	planCmd.Flags().Lookup("dry-run").Hidden = true
+	planCmd.Flags().Lookup("watch").Hidden = true
+}
+
+func runPlan(src, dst string) error {
+	fmt.Printf("Planning from %s to %s\n", src, dst)
+
+	t, buildConfig, err := buildModel(src, dst)
+	if err != nil {
+		return err
+	}
+	outputs, err := model.Render(t, buildConfig)
+	if err != nil {
+		return err
+	}
+	changes, err := model.Plan(outputs, buildConfig.FileRootPrefix, dst, buildConfig.Filter)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes. Live layout is up to date.")
+		return nil
+	}
+
+	counts := map[string]int{}
+	symbols := map[string]string{
+		model.ActionCreate: "+",
+		model.ActionModify: "~",
+		model.ActionDelete: "-",
+	}
+	for _, change := range changes {
+		counts[change.Action]++
+		fmt.Printf("  %s %-6s %s\n", symbols[change.Action], change.Action, change.Path)
+	}
+	for _, change := range changes {
+		if change.Diff != "" {
+			fmt.Printf("\n%s", change.Diff)
+		}
+	}
+	fmt.Printf("\nPlan: %d to create, %d to modify, %d to delete.\n",
+		counts[model.ActionCreate], counts[model.ActionModify], counts[model.ActionDelete])
+	return exitCode(planExitChanges)
+}
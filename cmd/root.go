@@ -21,12 +21,26 @@ For example:
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+
+	// Errors are printed once by Execute
+	SilenceErrors: true,
+}
+
+// exitCode is returned by commands to exit with a status other than 1
+// without printing an error
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		if code, ok := err.(exitCode); ok {
+			os.Exit(int(code))
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
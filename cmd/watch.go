@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/imburbank/terradim/model"
+	"github.com/spf13/viper"
+)
+
+// watchDebounce is how long watch waits for changes to settle before
+// rebuilding
+const watchDebounce = 200 * time.Millisecond
+
+// runWatch watches src and the root config and rebuilds the
+// combinations affected by each batch of changes until interrupted
+func runWatch(src, dst string) error {
+	src = srcPath(src)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err = watchDirs(watcher, src, dst); err != nil {
+		return err
+	}
+	if rootConfig := viper.GetString("root-config"); rootConfig != "" {
+		if err = watcher.Add(rootConfig); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Watching %s for changes\n", src)
+
+	changed := map[string]bool{}
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if ok == false {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err = watchDirs(watcher, event.Name, dst); err != nil {
+						fmt.Println(err)
+					}
+				}
+			}
+			changed[event.Name] = true
+			settled = time.After(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if ok == false {
+				return nil
+			}
+			fmt.Println(err)
+		case <-settled:
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			changed = map[string]bool{}
+			settled = nil
+			if err := rebuild(src, dst, paths); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+// watchDirs adds root and every dir below it to watcher, skipping dst
+// in case it is inside src
+func watchDirs(watcher *fsnotify.Watcher, root, dst string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() == false {
+			return nil
+		}
+		if filepath.Clean(path) == filepath.Clean(dst) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// rebuild writes the combinations affected by the changed paths. A
+// change to the root config or to a path outside any config fragment
+// rebuilds every combination of the dimensions it sits in. Combinations
+// outside --only and --exclude are never rebuilt.
+func rebuild(src, dst string, paths []string) error {
+	t, buildConfig, err := buildModel(src, dst)
+	if err != nil {
+		return err
+	}
+	selected := buildConfig.Filter
+
+	full := false
+	filters := map[string]*model.Filter{}
+	for _, path := range paths {
+		if path == viper.GetString("root-config") {
+			full = true
+			break
+		}
+		filter, ok := buildConfig.Affected(t, path)
+		if ok == false {
+			fmt.Printf("Change: %s affects no combinations\n", path)
+			continue
+		}
+		if filter.IsEmpty() {
+			full = true
+			break
+		}
+		filters[filter.String()] = filter
+	}
+	if full {
+		filters = map[string]*model.Filter{"all": nil}
+	}
+
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buildConfig.Filter = selected.And(filters[key])
+		fmt.Printf("Rebuilding %s\n", buildConfig.Filter)
+		if err := writeToFile(t, buildConfig); err != nil {
+			return err
+		}
+	}
+	if len(keys) > 0 {
+		fmt.Println("Build Complete")
+	}
+	return nil
+}
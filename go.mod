@@ -3,13 +3,19 @@ module github.com/imburbank/terradim
 go 1.13
 
 require (
-	github.com/bmatcuk/doublestar v1.2.0 // indirect
+	github.com/agext/levenshtein v1.2.2 // indirect
+	github.com/fsnotify/fsnotify v1.4.7
+	github.com/google/go-cmp v0.3.1 // indirect
 	github.com/hashicorp/hcl/v2 v2.1.0
-	github.com/hashicorp/terraform v0.12.16
+	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/mitchellh/go-wordwrap v1.0.0 // indirect
+	github.com/spf13/afero v1.2.1 // indirect
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.5.0
+	github.com/stretchr/testify v1.3.0 // indirect
 	github.com/zclconf/go-cty v1.1.1
-	golang.org/x/crypto v0.0.0-20191128160524-b544559bb6d1 // indirect
+	github.com/zclconf/go-cty-yaml v1.0.1
+	golang.org/x/sys v0.0.0-20190804053845-51ab0e2deafa // indirect
 	gopkg.in/yaml.v3 v3.0.0-20191120175047-4206685974f2
 )
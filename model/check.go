@@ -0,0 +1,75 @@
+package model
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Drift kinds of a path under dst compared to a fresh build
+const (
+	DriftAdded    = "added"
+	DriftRemoved  = "removed"
+	DriftModified = "modified"
+)
+
+// Check compares the filesystem under dst to outputs as Plan does, by
+// type, permissions and contents, and returns every drifted path sorted
+// by path. Paths under dst that are not outputs are added, outputs
+// missing from dst are removed and outputs that differ are modified,
+// with a diff from the built to the current contents when the contents
+// differ. Paths the dst manifest records for a combination
+// outside filter are not compared, as a filtered build does not render
+// them. The manifest itself is not compared.
+func Check(outputs *OutputSet, dst string, filter *Filter) ([]Change, error) {
+	changes := []Change{}
+	for _, path := range outputs.Paths() {
+		output, _ := outputs.Get(path)
+		info, changed, current, err := outputDrift(output)
+		if err != nil {
+			return nil, err
+		}
+		if changed == false {
+			continue
+		}
+		change := Change{Action: DriftModified, Path: path, IsDir: output.IsDir}
+		if info == nil {
+			change.Action = DriftRemoved
+		} else if current != nil && bytes.Equal(current, output.Data) == false {
+			change.Diff = unifiedDiff("built/"+path, "live/"+path, output.Data, current)
+		}
+		changes = append(changes, change)
+	}
+
+	manifest, err := ReadManifest(dst)
+	if err != nil {
+		return nil, err
+	}
+	manifestFile := filepath.Join(dst, ManifestFile)
+	err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path == manifestFile {
+			return nil
+		}
+		if entry := manifest.entry(dst, path); entry != nil && filter.MatchDims(entry.Dims) == false {
+			return nil
+		}
+		if _, ok := outputs.Get(path); ok == false {
+			changes = append(changes, Change{Action: DriftAdded, Path: path, IsDir: info.IsDir()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
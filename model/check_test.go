@@ -0,0 +1,79 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	os.Chmod(dst, 0755)
+	ioutil.WriteFile(filepath.Join(dst, "same.yaml"), []byte("a: 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dst, "env.yaml"), []byte("a: 1\nb: 2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dst, "handplaced.yaml"), []byte("a: 1\n"), 0644)
+	if err := (&Manifest{Files: map[string]*ManifestEntry{}}).Write(dst); err != nil {
+		t.Fatalf("Manifest write failed. Error: %v", err)
+	}
+
+	outputs := NewOutputSet()
+	outputs.add(&Output{Dst: dst, Mode: os.ModeDir | 0755, IsDir: true})
+	outputs.add(&Output{Dst: filepath.Join(dst, "same.yaml"), Mode: 0644, Data: []byte("a: 1\n")})
+	outputs.add(&Output{Dst: filepath.Join(dst, "env.yaml"), Mode: 0644, IsConfig: true, Data: []byte("a: 1\nb: 3\n")})
+	outputs.add(&Output{Dst: filepath.Join(dst, "missing.yaml"), Mode: 0644, Data: []byte("a: 1\n")})
+
+	drift, err := Check(outputs, dst, nil)
+	if err != nil {
+		t.Fatalf("Check failed. Error: %v", err)
+	}
+	expected := map[string]string{
+		"env.yaml":        DriftModified,
+		"handplaced.yaml": DriftAdded,
+		"missing.yaml":    DriftRemoved,
+	}
+	if len(drift) != len(expected) {
+		t.Fatalf("Check should have %d drifted paths. Drift: %+v", len(expected), drift)
+	}
+	for _, change := range drift {
+		if action := expected[filepath.Base(change.Path)]; action != change.Action {
+			t.Fatalf("%s should be %s. Change: %+v", change.Path, action, change)
+		}
+	}
+	if drift[0].Diff == "" {
+		t.Fatalf("Modified file should have a diff")
+	}
+}
+
+func TestCheckFilter(t *testing.T) {
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	tree, config, err := Create("../test/terradim", dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	if _, err = Write(tree, config); err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(dst, "qa", "md", "install.yaml"), []byte("edited\n"), 0644)
+
+	config.Filter, _ = config.ParseFilter([]string{"dim1=dev"}, nil)
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	drift, err := Check(outputs, dst, config.Filter)
+	if err != nil {
+		t.Fatalf("Check failed. Error: %v", err)
+	}
+	if len(drift) != 0 {
+		t.Fatalf("Combinations outside filter should not drift. Drift: %+v", drift)
+	}
+}
@@ -1,20 +1,22 @@
 package model
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
-// Copy file or dir from src to dst
+// Copy file or dir from src to dst. A dst file with the same contents
+// and mode as src is left untouched.
 func Copy(src, dst string) (err error) {
 	var info os.FileInfo
 
 	if info, err = os.Stat(src); err != nil {
 		return
 	}
-	if err = os.RemoveAll(dst); err != nil {
-		return
-	}
 
 	if info.IsDir() {
 		err = copyDir(src, dst, info)
@@ -25,26 +27,130 @@ func Copy(src, dst string) (err error) {
 }
 
 func copyDir(src, dst string, srcInfo os.FileInfo) (err error) {
+	if err = removeIfNotDir(dst, true); err != nil {
+		return
+	}
 	err = os.MkdirAll(dst, srcInfo.Mode())
 	return
 }
 
 func copyFile(src, dst string, srcInfo os.FileInfo) (err error) {
-	var srcFd, dstFd *os.File
-
-	if srcFd, err = os.Open(src); err != nil {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
 		return
 	}
-	defer srcFd.Close()
+	_, err = writeFile(dst, data, srcInfo.Mode())
+	return
+}
+
+// removeIfNotDir removes dst when it exists and whether it is a dir does
+// not match isDir
+func removeIfNotDir(dst string, isDir bool) error {
+	info, err := os.Lstat(dst)
+	if err == nil && info.IsDir() != isDir {
+		return os.RemoveAll(dst)
+	}
+	return nil
+}
 
-	if dstFd, err = os.Create(dst); err != nil {
+// writeOutput writes output to its dst and returns whether anything
+// changed. Existing dirs are kept so that files placed in them by hand
+// are left alone.
+func writeOutput(output *Output) (changed bool, err error) {
+	if err = removeIfNotDir(output.Dst, output.IsDir); err != nil {
 		return
 	}
-	defer dstFd.Close()
+	if output.IsDir {
+		info, statErr := os.Stat(output.Dst)
+		if statErr == nil && info.Mode().Perm() == output.Mode.Perm() {
+			return false, nil
+		}
+		if err = os.MkdirAll(output.Dst, output.Mode); err != nil {
+			return
+		}
+		err = os.Chmod(output.Dst, output.Mode.Perm())
+		return true, err
+	}
+	return writeFile(output.Dst, output.Data, output.Mode)
+}
+
+// writeFile writes data to dst unless dst already holds the same
+// contents, and returns whether anything changed. Files are compared by
+// size and then by hash. Changed files are written to a temp file next
+// to dst and renamed over it, so an interrupted write never leaves dst
+// half written.
+func writeFile(dst string, data []byte, mode os.FileMode) (bool, error) {
+	same, err := sameContents(dst, data)
+	if err != nil {
+		return false, err
+	}
+	if same {
+		info, err := os.Stat(dst)
+		if err != nil {
+			return false, err
+		}
+		if info.Mode().Perm() == mode.Perm() {
+			return false, nil
+		}
+		return true, os.Chmod(dst, mode.Perm())
+	}
+	return true, writeFileAtomic(dst, data, mode)
+}
+
+// sameContents returns true if the file at path holds data
+func sameContents(path string, data []byte) (bool, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Mode().IsRegular() == false || info.Size() != int64(len(data)) {
+		return false, nil
+	}
+	fd, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, fd); err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(data)
+	return bytes.Equal(hash.Sum(nil), sum[:]), nil
+}
 
-	if _, err = io.Copy(dstFd, srcFd); err != nil {
+// writeFileAtomic writes data to a temp file in the dir of dst and
+// renames it to dst
+func writeFileAtomic(dst string, data []byte, mode os.FileMode) (err error) {
+	dir, base := filepath.Split(dst)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return
+	}
+	if err = tmp.Chmod(mode.Perm()); err != nil {
+		return
+	}
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+	if err = tmp.Close(); err != nil {
 		return
 	}
-	err = os.Chmod(dst, srcInfo.Mode())
+	err = os.Rename(tmp.Name(), dst)
 	return
 }
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFileSkipsUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "install.yaml")
+	ioutil.WriteFile(dst, []byte("a: 1\n"), 0644)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(dst, old, old)
+
+	changed, err := writeFile(dst, []byte("a: 1\n"), 0644)
+	if err != nil {
+		t.Fatalf("writeFile failed. Error: %v", err)
+	}
+	info, _ := os.Stat(dst)
+	if changed || info.ModTime().Equal(old) == false {
+		t.Fatalf("Unchanged file should not be rewritten. Changed: %v", changed)
+	}
+
+	changed, err = writeFile(dst, []byte("a: 2\n"), 0600)
+	if err != nil {
+		t.Fatalf("writeFile failed. Error: %v", err)
+	}
+	data, _ := ioutil.ReadFile(dst)
+	info, _ = os.Stat(dst)
+	if changed == false || string(data) != "a: 2\n" || info.Mode().Perm() != 0600 {
+		t.Fatalf("Changed file should be rewritten. Data: %q Mode: %v", data, info.Mode())
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("Temp files should be renamed away. Entries: %d", len(entries))
+	}
+}
@@ -4,24 +4,25 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/bits"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 
-	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsyntax"
-	"github.com/hashicorp/terraform/lang"
 	"github.com/spf13/viper"
-	"github.com/zclconf/go-cty/cty"
-	"github.com/zclconf/go-cty/cty/gocty"
 	"gopkg.in/yaml.v3"
 )
 
 // TerradimConfig is the marshalled config for a terrdim file (ex. n1.yaml)
 type TerradimConfig struct {
 	Path   string
+	Depth  int
 	Config nodeConfig
+	schema *schema
+	// ancestors are the dimensions the enum dir is nested in
+	ancestors []string
 }
 
 // TerradimConfigMap hold paths for terradim enum dirs
@@ -42,39 +43,90 @@ type BuildConfig struct {
 	ConfigMap      TerradimConfigMap
 	FileRootPrefix string
 	FileOutPrefix  string
+	RootConfig     string
 	PathSeparator  string
+	KeepGoing      bool
+	KeepOrphans    bool
+	Jobs           int
+	Filter         *Filter
+	spec           *spec
+	jobs           chan struct{}
+	// explain keeps the merge history of config outfiles for Explain
+	explain bool
 }
 
+// buildData holds the state of the combination being rendered. Each
+// enum value renders with its own copy so combinations can be rendered
+// concurrently.
 type buildData map[string]interface{}
 
+// clone returns a copy of the build data for rendering a nested
+// combination. Resolved dimension configs are copied so that siblings
+// do not see each other's configs.
+func (d buildData) clone() buildData {
+	data := make(buildData, len(d)+1)
+	for key, val := range d {
+		data[key] = val
+	}
+	if dimConfigs, ok := d["dimConfigs"].(map[string]*mergedConfig); ok {
+		configs := make(map[string]*mergedConfig, len(dimConfigs))
+		for dim, config := range dimConfigs {
+			configs[dim] = config
+		}
+		data["dimConfigs"] = configs
+	}
+	return data
+}
+
 type nodeConfig struct {
-	Name    string   `yaml:"name"`
-	Outfile string   `yaml:"outfile"`
-	Enum    []string `yaml:"enum,flow"`
+	Name    string        `yaml:"name"`
+	Outfile string        `yaml:"outfile"`
+	Format  string        `yaml:"format"`
+	Order   int           `yaml:"order"`
+	Enum    enumValues    `yaml:"enum,flow"`
+	Merge   mergeConfig   `yaml:"merge"`
+	Inherit string        `yaml:"inherit"`
+	Exclude []matrixEntry `yaml:"exclude"`
+	Include []matrixEntry `yaml:"include"`
+	Schema  yaml.Node     `yaml:"schema"`
 }
 
-// ModelConfig list enumerable dirs
-var ModelConfig = TerradimConfigMap{
-	"dim1": &TerradimConfig{Config: nodeConfig{}},
-	"dim2": &TerradimConfig{Config: nodeConfig{}},
+// Create tree model and validate its dimension descriptors. The root
+// config at rootConfig, when set, declares dimensions and their spec.
+func Create(srcpath, dstpath, rootConfig string) (*Tree, *BuildConfig, error) {
+	model, buildConfig, err := Load(srcpath, dstpath, rootConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = buildConfig.validate(); err != nil {
+		return nil, nil, err
+	}
+	return model, buildConfig, nil
 }
 
-// Create tree model
-func Create(srcpath, dstpath string) (*Tree, *BuildConfig) {
+// Load reads the tree model like Create but without validating the
+// dimension descriptors, so that Lint can report every problem
+func Load(srcpath, dstpath, rootConfig string) (*Tree, *BuildConfig, error) {
 	var (
 		parent     *Node
 		parentMeta NodeMeta
 	)
 	dirname, basename := filepath.Split(srcpath)
 	model := NewTree()
+	configMap, rootSpec, err := loadDimensions(srcpath, rootConfig)
+	if err != nil {
+		return nil, nil, err
+	}
 	buildConfig := &BuildConfig{
-		ConfigMap:      ModelConfig,
+		ConfigMap:      configMap,
 		FileRootPrefix: srcpath,
 		FileOutPrefix:  dstpath,
+		RootConfig:     rootConfig,
 		PathSeparator:  model.Separator(),
+		spec:           rootSpec,
 	}
 	lastDirname := srcpath
-	err := filepath.Walk(srcpath,
+	err = filepath.Walk(srcpath,
 		func(curpath string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -88,7 +140,7 @@ func Create(srcpath, dstpath string) (*Tree, *BuildConfig) {
 			if curpath != srcpath && dirname != lastDirname {
 				lastDirname = dirname
 				parent, _ = model.Find(dirname[:len(dirname)-1])
-				if parent.meta == nil {
+				if parent == nil || parent.meta == nil {
 					parentMeta = NodeMeta{}
 				} else {
 					parentMeta = parent.Meta().(NodeMeta)
@@ -96,59 +148,171 @@ func Create(srcpath, dstpath string) (*Tree, *BuildConfig) {
 			}
 			if info.IsDir() {
 				meta.IsDir = true
-				if _, ok := ModelConfig[basename]; ok == true {
-					if err != nil {
-						return err
-					}
+				if _, ok := configMap[basename]; ok == true {
 					meta.IsEnum = true
 					buildConfig.ConfigMap[basename].Path = curpath
 				} else {
 					dirParts := strings.SplitN(basename, "_", 2)
-					_, ok := ModelConfig[dirParts[0]]
-					if ok && dirParts[1] == "config" {
+					_, ok := configMap[dirParts[0]]
+					if ok && len(dirParts) == 2 && dirParts[1] == "config" {
 						meta.IsConfig = true
 					}
 				}
 			} else {
 				fileParts := strings.SplitN(basename, ".", 2)
-				_, ok := ModelConfig[fileParts[0]]
-				if ok && fileParts[1] == "yaml" {
+				_, ok := configMap[fileParts[0]]
+				isDescriptor := filepath.Base(dirname) == fileParts[0]
+				if ok && isDescriptor && len(fileParts) == 2 && fileParts[1] == "yaml" {
 					meta.IsEnum = true
 					meta.IsConfig = true
-					buildConfig.ConfigMap[fileParts[0]].Config = loadNodeConfig(curpath)
+					config, err := loadNodeConfig(curpath)
+					if err != nil {
+						return err
+					}
+					buildConfig.ConfigMap[fileParts[0]].Config = config
 				}
 			}
 			if parentMeta.IsConfig == true {
 				meta.IsConfig = true
 			}
 
-			_, _ = model.Insert(curpath, meta)
+			node, _ := model.Insert(curpath, meta)
+			if meta.IsEnum && meta.IsDir {
+				ancestors := dimAncestors(node)
+				buildConfig.ConfigMap[basename].Depth = len(ancestors) + 1
+				buildConfig.ConfigMap[basename].ancestors = nil
+				for _, ancestor := range ancestors {
+					buildConfig.ConfigMap[basename].ancestors = append(buildConfig.ConfigMap[basename].ancestors, ancestor.Key())
+				}
+			}
 			if viper.GetBool("verbose") == true {
 				fmt.Printf("Insert: %s - %+v\n", curpath, meta)
 			}
 			return nil
 		})
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
-	return model, buildConfig
+	return model, buildConfig, nil
 }
 
-func loadNodeConfig(curpath string) nodeConfig {
+func loadNodeConfig(curpath string) (nodeConfig, error) {
+	config, err := readNodeConfig(curpath)
+	if err != nil {
+		return config, pathErrorf(curpath, ErrBadDescriptor, "%s", err)
+	}
+	return config, nil
+}
+
+func readNodeConfig(curpath string) (nodeConfig, error) {
 	var config nodeConfig
 	filedata, err := ioutil.ReadFile(curpath)
 	if err != nil {
-		panic(err)
+		return config, err
+	}
+	err = yaml.Unmarshal(filedata, &config)
+	return config, err
+}
+
+// WriteResult lists the paths a Write found orphaned under dst, paths
+// that were generated by the previous build and are no longer outputs
+type WriteResult struct {
+	// Orphans are the orphaned paths left in dst
+	Orphans []string
+	// Pruned are the orphaned paths removed from dst
+	Pruned []string
+}
+
+// Write model to file. Paths generated by the previous build that are
+// no longer outputs are pruned, or only returned as orphans with
+// KeepOrphans set in config. With KeepGoing set in config the build continues past
+// failures, writes what it could and returns all failures as a
+// MultiError; nothing is pruned then. With a Filter set in config only
+// the selected combinations are written and pruned, and the manifest
+// keeps the entries of every other combination.
+func Write(t *Tree, config *BuildConfig) (*WriteResult, error) {
+	result := &WriteResult{}
+	outputs, err := Render(t, config)
+	if err != nil && config.KeepGoing == false {
+		return result, err
+	}
+	var errs MultiError
+	errs = errs.Append(err)
+
+	dst := config.FileOutPrefix
+	prev, err := ReadManifest(dst)
+	if err != nil {
+		return result, errs.Append(err).ErrorOrNil()
+	}
+	manifest, err := nextManifest(prev, outputs, config.FileRootPrefix, dst, config.Filter)
+	if err != nil {
+		return result, errs.Append(err).ErrorOrNil()
+	}
+	orphans := prev.Orphans(outputs, dst, config.Filter)
+
+	if err = Apply(outputs); err != nil {
+		return result, errs.Append(err).ErrorOrNil()
+	}
+
+	if len(errs) > 0 || config.KeepOrphans {
+		// Orphans stay in the manifest until a build prunes them
+		for _, rel := range orphans {
+			manifest.Files[rel] = prev.Files[rel]
+			result.Orphans = append(result.Orphans, manifestPath(dst, rel))
+		}
+	} else {
+		for _, rel := range orphans {
+			pruned, err := prune(dst, manifestPath(dst, rel))
+			if err != nil {
+				return result, err
+			}
+			if pruned {
+				result.Pruned = append(result.Pruned, manifestPath(dst, rel))
+			} else {
+				// Dirs holding files placed by hand are kept
+				manifest.Files[rel] = prev.Files[rel]
+			}
+		}
 	}
-	yaml.Unmarshal(filedata, &config)
-	return config
+	return result, errs.Append(manifest.Write(dst)).ErrorOrNil()
 }
 
-// Write model to file
-func Write(t *Tree, config *BuildConfig) error {
+// Render builds the model in memory and returns the outputs that Write
+// would write. Up to Jobs enum combinations set in config are rendered
+// concurrently.
+func Render(t *Tree, config *BuildConfig) (*OutputSet, error) {
 	root := t.Root()
-	WalkSubtree(root, buildFunc, buildData{"buildConfig": config})
-	return nil
+	outputs := NewOutputSet()
+	config.jobs = nil
+	if config.Jobs > 1 {
+		// The walking goroutine renders too, so it takes one of the jobs
+		config.jobs = make(chan struct{}, config.Jobs-1)
+	}
+	err := config.walkSubtree(root, buildFunc, buildData{"buildConfig": config, "outputs": outputs})
+	return outputs, err
+}
+
+// acquireJob returns true if a combination can be rendered in a new
+// goroutine. It never blocks, so a combination waiting on its nested
+// combinations renders them itself when all jobs are taken.
+func (c *BuildConfig) acquireJob() bool {
+	select {
+	case c.jobs <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *BuildConfig) releaseJob() {
+	<-c.jobs
+}
+
+func (c *BuildConfig) walkSubtree(node *Node, walkFn WalkFunc, data interface{}) error {
+	if c.KeepGoing {
+		return WalkSubtreeAll(node, walkFn, data)
+	}
+	return WalkSubtree(node, walkFn, data)
 }
 
 // buildFunc is a Tree WalkFunc for writing model to filesystem
@@ -170,39 +334,56 @@ func buildFunc(node *Node, data interface{}) (bool, error) {
 		return false, nil
 	}
 	if meta.IsEnum && meta.IsDir {
-		keyNum, err := strconv.Atoi(key[len(key)-1:])
-		if err != nil {
-			return false, err
-		}
-
-		for _, enum := range buildConfig.ConfigMap[key].Config.Enum {
-			dataMap[key] = enum
-			for dim := range buildConfig.ConfigMap {
-				if _, ok := dataMap[dim].(string); ok && dim != key {
-					dimLevel, err := strconv.Atoi(dim[len(dim)-1:])
-					if err != nil {
-						panic(err)
-					}
-					if dimLevel > keyNum {
-						delete(dataMap, dim)
+		var wg sync.WaitGroup
+		keyLevel := buildConfig.ConfigMap[key].Level()
+		enums := buildConfig.ConfigMap[key].Config.Enum.Names()
+		enumErrs := make([]error, len(enums))
+
+		for i, enum := range enums {
+			if buildConfig.Filter.Match(key, enum) == false {
+				continue
+			}
+			enumData := dataMap.clone()
+			enumData[key] = enum
+			for dim, dimConfig := range buildConfig.ConfigMap {
+				if _, ok := enumData[dim].(string); ok && dim != key {
+					if dimConfig.Level() > keyLevel {
+						delete(enumData, dim)
 					}
 				}
 			}
-			_, err = copyToDst(path, &dataMap)
-			if err != nil {
-				return true, err
+			if buildConfig.excluded(enumData) {
+				continue
 			}
-
-			_, err = writeDimConfig(node, &dataMap)
-			if err != nil {
-				return true, err
+			build := func(i int, enum string, enumData buildData) {
+				if err := buildEnum(node, &enumData); err != nil {
+					enumErrs[i] = fmt.Errorf("%s=%s: %w", key, enum, err)
+				}
 			}
+			if buildConfig.acquireJob() {
+				wg.Add(1)
+				go func(i int, enum string, enumData buildData) {
+					defer wg.Done()
+					defer buildConfig.releaseJob()
+					build(i, enum, enumData)
+				}(i, enum, enumData)
+				continue
+			}
+			build(i, enum, enumData)
+			if enumErrs[i] != nil && buildConfig.KeepGoing == false {
+				break
+			}
+		}
+		wg.Wait()
 
-			for _, child := range node.Children() {
-				WalkSubtree(child, buildFunc, dataMap)
+		var errs MultiError
+		for _, err := range enumErrs {
+			if err != nil && buildConfig.KeepGoing == false {
+				return false, err
 			}
+			errs = errs.Append(err)
 		}
-		return false, nil
+		return false, errs.ErrorOrNil()
 	}
 	_, err = copyToDst(path, &dataMap)
 	if err != nil {
@@ -211,6 +392,29 @@ func buildFunc(node *Node, data interface{}) (bool, error) {
 	return true, nil
 }
 
+// buildEnum writes the enum dir and config for the active value of the
+// node dimension, then walks its children
+func buildEnum(node *Node, data *buildData) error {
+	dataMap := *data
+	buildConfig := dataMap["buildConfig"].(*BuildConfig)
+	if _, err := copyToDst(node.Path(), data); err != nil {
+		return err
+	}
+	if _, err := writeDimConfig(node, data); err != nil {
+		return err
+	}
+
+	var errs MultiError
+	for _, child := range node.Children() {
+		err := buildConfig.walkSubtree(child, buildFunc, dataMap)
+		if err != nil && buildConfig.KeepGoing == false {
+			return err
+		}
+		errs = errs.Append(err)
+	}
+	return errs.ErrorOrNil()
+}
+
 func createWritePath(src string, data *buildData) (dst string, err error) {
 	dataMap := *data
 	buildConfig, ok := dataMap["buildConfig"].(*BuildConfig)
@@ -218,19 +422,22 @@ func createWritePath(src string, data *buildData) (dst string, err error) {
 		return "", errors.New("buildFunc: Must pass in BuildConfig as data")
 	}
 
-	dst = buildConfig.FileOutPrefix + src[len(buildConfig.FileRootPrefix):]
+	// Only the src-relative segments name dimensions, dst itself may
+	// contain a dir with the name of a dimension
 	sep := buildConfig.PathSeparator
-
-	for dim := range buildConfig.ConfigMap {
-		if val, ok := dataMap[dim]; ok {
-			dst = strings.Replace(dst, fmt.Sprintf("%s%s%s", sep, dim, sep),
-				fmt.Sprintf("%s%s%s", sep, val, sep), 1)
-
-			if dir, file := filepath.Split(dst); file == dim {
-				dst = fmt.Sprintf("%s%s", dir, val)
-			}
+	segments := strings.Split(src[len(buildConfig.FileRootPrefix):], sep)
+	replaced := map[string]bool{}
+	for i, segment := range segments {
+		dimConfig, ok := buildConfig.ConfigMap[segment]
+		if ok == false || replaced[segment] {
+			continue
+		}
+		if val, ok := dataMap[segment].(string); ok {
+			segments[i] = dimConfig.Config.Enum.dir(val)
+			replaced[segment] = true
 		}
 	}
+	dst = buildConfig.FileOutPrefix + strings.Join(segments, sep)
 	return
 }
 
@@ -240,74 +447,198 @@ func copyToDst(path string, data *buildData) (dst string, err error) {
 		return
 	}
 
-	err = Copy(path, dst)
-	//TODO: if flag --verbose fmt.Printf("Write: %s  ->  %s\n", path, dst)
-	if viper.GetBool("verbose") == true {
-		fmt.Printf("Write: %s  ->  %s\n", path, dst)
+	output, err := readOutput(path, dst)
+	if err != nil {
+		return
+	}
+	if ok, suffix := isTemplate(path, output.Data); ok && output.IsDir == false {
+		if output.Data, err = renderTemplate(path, output.Data, *data); err != nil {
+			return
+		}
+		dst = strings.TrimSuffix(dst, suffix)
+		output.Dst = dst
 	}
+	output.Dims = combination(*data)
+	err = (*data)["outputs"].(*OutputSet).add(output)
 	return
 }
 
+// combination returns the active enum value of each dimension
+func combination(dataMap buildData) map[string]string {
+	buildConfig := dataMap["buildConfig"].(*BuildConfig)
+	dims := map[string]string{}
+	for dim := range buildConfig.ConfigMap {
+		if val, ok := dataMap[dim].(string); ok {
+			dims[dim] = val
+		}
+	}
+	return dims
+}
+
+// fragmentExts are the extensions config fragments are looked up with
+var fragmentExts = []string{".yaml", ".yml", ".json", ".hcl"}
+
+// collectDimConfigs returns the config fragment paths for the active
+// value of the enumNode dimension, ordered from least to most specific.
+// See configKeys for the fragment names. Each name is looked up in
+// <dim>_config/ and then in <dim>_config/<value>/, in any of the
+// fragment formats.
 func collectDimConfigs(enumNode *Node, data *buildData) ([]string, error) {
 	dims := []string{}
 	dataMap := *data
-	ext := ".yaml"
 	key := enumNode.Key()
+	buildConfig, ok := dataMap["buildConfig"].(*BuildConfig)
+	if ok == false {
+		return nil, errors.New("buildFunc: Must pass in BuildConfig as data")
+	}
 	configNode := enumNode.getChild(key + "_config")
-	dimBase := key[:len(key)-1]
-	dimLevel, err := strconv.Atoi(key[len(key)-1:])
-	if err != nil {
-		return nil, err
+	if configNode == nil {
+		return dims, nil
+	}
+	value := dataMap[key].(string)
+	valueNode := configNode.getChild(value)
+
+	outer := []string{}
+	activeDims, _ := buildConfig.activeDims(dataMap)
+	for _, dim := range activeDims {
+		if dim != key && buildConfig.ConfigMap[dim].Level() < buildConfig.ConfigMap[key].Level() {
+			outer = append(outer, dataMap[dim].(string))
+		}
 	}
 
-	if child := configNode.getChild(dataMap[key].(string) + ext); child != nil {
-		dims = append(dims, child.Path())
+	for _, configKey := range configKeys(value, outer) {
+		for _, dirNode := range []*Node{configNode, valueNode} {
+			if dirNode == nil {
+				continue
+			}
+			path, err := findFragment(dirNode, configKey)
+			if err != nil {
+				return nil, err
+			}
+			if path != "" {
+				dims = append(dims, path)
+			}
+		}
 	}
-	if child := configNode.getChild(dataMap[key].(string)); child != nil && dimLevel == 2 {
-		if gChild := child.getChild(dataMap[key].(string) + ext); gChild != nil {
-			dims = append(dims, gChild.Path())
+	return dims, nil
+}
+
+// findFragment returns the path of the fragment named configKey in
+// dirNode, or an empty string if there is none. A fragment found in
+// more than one format is an error as its layer order is ambiguous.
+func findFragment(dirNode *Node, configKey string) (string, error) {
+	found := []string{}
+	for _, ext := range fragmentExts {
+		if child := dirNode.getChild(configKey + ext); child != nil {
+			found = append(found, child.Path())
 		}
-		gridKey := fmt.Sprintf("%s_%s%s", dataMap[key], dataMap[dimBase+"1"], ext)
-		if gChild := child.getChild(dataMap[key].(string) + ext); gChild != nil {
-			dims = append(dims, gChild.Path())
+	}
+	if len(found) > 1 {
+		return "", pathErrorf(found[0], ErrDuplicateLayer, "also found as %s", strings.Join(found[1:], ", "))
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return found[0], nil
+}
+
+// configKeys returns the fragment names for value combined with every
+// subset of the outer dimension values, ex. ok, ok_dev, ok_us-east-1,
+// ok_dev_us-east-1 for outer values [dev, us-east-1]. Outer values keep
+// their nesting order within a name. Names with fewer outer values come
+// first, and among names with as many outer values those including
+// deeper dimensions come later.
+func configKeys(value string, outer []string) []string {
+	masks := make([]int, 1<<uint(len(outer)))
+	for i := range masks {
+		masks[i] = i
+	}
+	sort.SliceStable(masks, func(i, j int) bool {
+		ci, cj := bits.OnesCount(uint(masks[i])), bits.OnesCount(uint(masks[j]))
+		if ci != cj {
+			return ci < cj
 		}
-		if gChild := child.getChild(gridKey); gChild != nil {
-			dims = append(dims, gChild.Path())
+		return masks[i] < masks[j]
+	})
+
+	keys := make([]string, 0, len(masks))
+	for _, mask := range masks {
+		parts := []string{value}
+		for i, val := range outer {
+			if mask&(1<<uint(i)) != 0 {
+				parts = append(parts, val)
+			}
 		}
+		keys = append(keys, strings.Join(parts, "_"))
 	}
-	return dims, nil
+	return keys
 }
 
-func mergeDimConfigs(configPaths []string) (string, error) {
-	for i, val := range configPaths {
-		configPaths[i] = fmt.Sprintf(`yamldecode(file("%s"))`, val)
+// resolveDimConfig merges and interpolates the config for the active
+// value of the enumNode dimension. The result is kept in data so that
+// nested dimensions can inherit it.
+func resolveDimConfig(enumNode *Node, data *buildData) (*mergedConfig, error) {
+	dataMap := *data
+	key := enumNode.Key()
+	buildConfig, ok := dataMap["buildConfig"].(*BuildConfig)
+	if ok == false {
+		return nil, errors.New("buildFunc: Must pass in BuildConfig as data")
+	}
+	dimConfigs, ok := dataMap["dimConfigs"].(map[string]*mergedConfig)
+	if ok == false {
+		dimConfigs = map[string]*mergedConfig{}
+		dataMap["dimConfigs"] = dimConfigs
 	}
 
-	exprTxt := fmt.Sprintf(`yamlencode(merge(%s))`, strings.Join(configPaths, ","))
-	// TODO: Figure out how to set filename
-	fileName := "config"
-	expr, parseDiags := hclsyntax.ParseExpression([]byte(exprTxt), fileName, hcl.Pos{Line: 1, Column: 1})
-	if parseDiags.HasErrors() {
-		for _, diag := range parseDiags {
-			fmt.Println(diag.Error())
-		}
-		return "", errors.New("mergeDimConfigs: ParseExpression Error")
+	layers, overrides, err := buildConfig.specLayers(key, dataMap)
+	if err != nil {
+		return nil, err
 	}
 
-	scope := &lang.Scope{
-		BaseDir: ".",
+	configPaths, err := collectDimConfigs(enumNode, data)
+	if err != nil {
+		return nil, err
 	}
-	got, diags := scope.EvalExpr(expr, cty.String)
-	if diags.HasErrors() {
-		for _, diag := range diags {
-			fmt.Printf("%s: %s", diag.Description().Summary, diag.Description().Detail)
+
+	fileLayers, err := readConfigLayers(configPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	config := buildConfig.ConfigMap[key].Config
+	merged := newMergedConfig()
+	dims, values := buildConfig.activeDims(dataMap)
+	if config.Inherit != "" {
+		parentDim := ""
+		for _, dim := range dims {
+			if buildConfig.ConfigMap[dim].Level() < buildConfig.ConfigMap[key].Level() {
+				parentDim = dim
+			}
+		}
+		if parent, ok := dimConfigs[parentDim]; ok {
+			namespace := buildConfig.ConfigMap[parentDim].Config.Name
+			if namespace == "" {
+				namespace = parentDim
+			}
+			if merged, err = parent.inherit(config.Inherit, namespace); err != nil {
+				return nil, fmt.Errorf("%s: %s", key, err)
+			}
 		}
-		return "", errors.New("mergeDimConfigs: EvalExpr Error")
 	}
 
-	var config string
-	err := gocty.FromCtyValue(got, &config)
-	return config, err
+	if buildConfig.explain && merged.History == nil {
+		merged.History = map[string][]keySetting{}
+	}
+	layers = append(append(layers, fileLayers...), overrides...)
+	if err = merged.mergeLayers(layers, config.Merge); err != nil {
+		return nil, err
+	}
+
+	if err = merged.interpolate(values, buildConfig.enumObjects(dims, values)); err != nil {
+		return nil, err
+	}
+	dimConfigs[key] = merged
+	return merged, nil
 }
 
 func writeDimConfig(enumNode *Node, data *buildData) (string, error) {
@@ -317,18 +648,22 @@ func writeDimConfig(enumNode *Node, data *buildData) (string, error) {
 		return "", errors.New("buildFunc: Must pass in BuildConfig as data")
 	}
 
-	configPaths, err := collectDimConfigs(enumNode, data)
+	merged, err := resolveDimConfig(enumNode, data)
 	if err != nil {
 		return "", err
 	}
+	if err = buildConfig.ConfigMap[enumNode.Key()].checkSchema(merged); err != nil {
+		return "", err
+	}
 
-	dimConfig, err := mergeDimConfigs(configPaths)
+	config := buildConfig.ConfigMap[enumNode.Key()].Config
+	dimConfig, err := encodeDimConfig(merged.Value, config.outfileFormat())
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%s: %s", config.Outfile, err)
 	}
 
 	enumPath := enumNode.Path()
-	configName := buildConfig.ConfigMap[enumNode.Key()].Config.Outfile
+	configName := config.Outfile
 	configPath := fmt.Sprintf("%s%s%s", enumPath, enumNode.Sep(), configName)
 	dst, err := createWritePath(configPath, data)
 	if err != nil {
@@ -340,11 +675,20 @@ func writeDimConfig(enumNode *Node, data *buildData) (string, error) {
 		return "", err
 	}
 
-	err = ioutil.WriteFile(dst, []byte(dimConfig), info.Mode())
-
-	// TODO: if flag --verbose fmt.Printf("Write Config: ->  %s\n", writePath)
-	if viper.GetBool("verbose") == true {
-		fmt.Printf("Write Config: ->  %s\n", dst)
+	output := &Output{
+		Src:       fmt.Sprintf("%s%s%s.yaml", enumPath, enumNode.Sep(), enumNode.Key()),
+		Dst:       dst,
+		Mode:      info.Mode().Perm(),
+		IsConfig:  true,
+		Data:      []byte(dimConfig),
+		Dims:      combination(dataMap),
+		Fragments: merged.Layers,
+	}
+	if buildConfig.explain {
+		output.merged = merged
+	}
+	if err = dataMap["outputs"].(*OutputSet).add(output); err != nil {
+		return "", err
 	}
-	return dst, err
+	return dst, nil
 }
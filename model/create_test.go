@@ -0,0 +1,385 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigKeys(t *testing.T) {
+	keys := configKeys("ok", []string{"dev", "us-east-1"})
+	expected := []string{"ok", "ok_dev", "ok_us-east-1", "ok_dev_us-east-1"}
+	if len(keys) != len(expected) {
+		t.Fatalf("Keys should be %v. Keys: %v", expected, keys)
+	}
+	for i, key := range expected {
+		if keys[i] != key {
+			t.Fatalf("Keys should be %v. Keys: %v", expected, keys)
+		}
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	descriptor := filepath.Join(src, "env", "env.yaml")
+	os.MkdirAll(filepath.Dir(descriptor), 0755)
+
+	ioutil.WriteFile(descriptor, []byte("enum: [dev, dev]\n"), 0644)
+	_, _, err = Create(src, filepath.Join(src, "live"), "")
+	if errors.Is(err, ErrBadEnum) == false {
+		t.Fatalf("Duplicate enum should return ErrBadEnum. Error: %v", err)
+	}
+	var pathErr *PathError
+	if errors.As(err, &pathErr) == false || pathErr.Path != descriptor {
+		t.Fatalf("Error should carry descriptor path. Error: %v", err)
+	}
+
+	ioutil.WriteFile(descriptor, []byte("enum: [dev\n"), 0644)
+	_, _, err = Create(src, filepath.Join(src, "live"), "")
+	if errors.Is(err, ErrBadDescriptor) == false {
+		t.Fatalf("Invalid descriptor should return ErrBadDescriptor. Error: %v", err)
+	}
+
+	ioutil.WriteFile(descriptor, []byte("enum: [dev]\n"), 0644)
+	_, _, err = Create(src, filepath.Join(src, "live"), "")
+	if errors.Is(err, ErrBadDescriptor) == false || strings.Contains(err.Error(), "outfile") == false {
+		t.Fatalf("Empty outfile should return ErrBadDescriptor. Error: %v", err)
+	}
+}
+
+func TestRenderJobs(t *testing.T) {
+	render := func(jobs int) *OutputSet {
+		tree, config, err := Create("../test/terradim", "../test/live", "")
+		if err != nil {
+			t.Fatalf("Create failed. Error: %v", err)
+		}
+		config.Jobs = jobs
+		outputs, err := Render(tree, config)
+		if err != nil {
+			t.Fatalf("Render with %d jobs failed. Error: %v", jobs, err)
+		}
+		return outputs
+	}
+	serial, parallel := render(1), render(8)
+	paths := serial.Paths()
+	if len(paths) != parallel.Len() {
+		t.Fatalf("Parallel render should have %d outputs. Outputs: %d", len(paths), parallel.Len())
+	}
+	for _, path := range paths {
+		want, _ := serial.Get(path)
+		got, ok := parallel.Get(path)
+		if ok == false || string(got.Data) != string(want.Data) {
+			t.Fatalf("Parallel render of %s should match serial render", path)
+		}
+	}
+	if output, _ := serial.Get("../test/live/dev/ok/install.yaml"); output == nil || output.Dims["dim2"] != "ok" {
+		t.Fatalf("Outfile should be rendered for dim2=ok. Output: %+v", output)
+	}
+}
+
+func TestEnumDir(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	descriptor := filepath.Join(src, "env", "env.yaml")
+	os.MkdirAll(filepath.Join(src, "env", "env_config"), 0755)
+	ioutil.WriteFile(descriptor, []byte(`outfile: env.yaml
+enum:
+  - dev
+  - name: prod
+    dir: production
+    tags: [critical]
+`), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "env_config", "prod.yaml"),
+		[]byte("args:\n  tags: \"$(dim.env.tags)\"\n"), 0644)
+
+	dst := filepath.Join(src, "live")
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	if _, ok := outputs.Get(filepath.Join(dst, "dev", "env.yaml")); ok == false {
+		t.Fatalf("Plain enum value should be written to its name")
+	}
+	output, ok := outputs.Get(filepath.Join(dst, "production", "env.yaml"))
+	if ok == false {
+		t.Fatalf("Enum value should be written to its dir. Outputs: %v", outputs.Paths())
+	}
+	if strings.Contains(string(output.Data), `- "critical"`) == false {
+		t.Fatalf("Enum metadata should be interpolated. Config: %s", output.Data)
+	}
+
+	ioutil.WriteFile(descriptor, []byte("enum: [{dir: production}]\n"), 0644)
+	if _, _, err = Create(src, dst, ""); errors.Is(err, ErrBadDescriptor) == false {
+		t.Fatalf("Enum value without name should return ErrBadDescriptor. Error: %v", err)
+	}
+}
+
+func TestFragmentFormats(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	configDir := filepath.Join(src, "env", "install", "install_config", "ok")
+	os.MkdirAll(configDir, 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte("name: env\noutfile: env.yaml\nenum: [dev]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "install", "install.yaml"),
+		[]byte("name: install\noutfile: install.yaml\nenum: [ok]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(configDir, "ok.json"),
+		[]byte(`{"args": {"region": "us-east-1", "size": 1, "owner": "json"}}`), 0644)
+	ioutil.WriteFile(filepath.Join(configDir, "ok_dev.hcl"),
+		[]byte("args = {\n  size = 3\n  zones = [\"a\", \"b\"]\n}\n"), 0644)
+	ioutil.WriteFile(filepath.Join(filepath.Dir(configDir), "ok.yml"), []byte("args:\n  owner: yml\n  tier: web\n"), 0644)
+
+	dst := filepath.Join(src, "live")
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	output, _ := outputs.Get(filepath.Join(dst, "dev", "ok", "install.yaml"))
+	for _, expected := range []string{`"region": "us-east-1"`, `"size": 3`, `"owner": "json"`, `"tier": "web"`, `- "b"`} {
+		if output == nil || strings.Contains(string(output.Data), expected) == false {
+			t.Fatalf("Config should contain %s. Output: %+v", expected, output)
+		}
+	}
+	if len(output.Fragments) != 3 || filepath.Ext(output.Fragments[0]) != ".yml" || filepath.Ext(output.Fragments[2]) != ".hcl" {
+		t.Fatalf("YML fragment should be layered first and HCL fragment last. Fragments: %v", output.Fragments)
+	}
+
+	ioutil.WriteFile(filepath.Join(configDir, "ok.yaml"), []byte("args: {}\n"), 0644)
+	tree, config, _ = Create(src, dst, "")
+	if _, err = Render(tree, config); errors.Is(err, ErrDuplicateLayer) == false {
+		t.Fatalf("Fragment in two formats should return ErrDuplicateLayer. Error: %v", err)
+	}
+
+	os.Remove(filepath.Join(configDir, "ok.yaml"))
+	ioutil.WriteFile(filepath.Join(filepath.Dir(configDir), "ok.yaml"), []byte("args: {}\n"), 0644)
+	tree, config, _ = Create(src, dst, "")
+	if _, err = Render(tree, config); errors.Is(err, ErrDuplicateLayer) == false {
+		t.Fatalf("Fragment as .yaml and .yml should return ErrDuplicateLayer. Error: %v", err)
+	}
+}
+
+func TestRenderInherit(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "env", "env_config"), 0755)
+	os.MkdirAll(filepath.Join(src, "env", "install", "install_config"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte("name: env\noutfile: env.yaml\nenum: [dev]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "env_config", "dev.yaml"), []byte("args:\n  region: us-east-1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "install", "install_config", "ok.yaml"), []byte("args:\n  size: 3\n"), 0644)
+
+	dst := filepath.Join(src, "live")
+	tests := []struct {
+		inherit  string
+		expected []string
+	}{
+		{InheritMerge, []string{"\"region\": \"us-east-1\"", "\"size\": 3"}},
+		{InheritNamespace, []string{"\"env\":\n  \"args\":\n    \"region\": \"us-east-1\"", "\"size\": 3"}},
+	}
+	for _, test := range tests {
+		ioutil.WriteFile(filepath.Join(src, "env", "install", "install.yaml"),
+			[]byte("name: install\noutfile: install.yaml\ninherit: "+test.inherit+"\nenum: [ok]\n"), 0644)
+		tree, config, err := Create(src, dst, "")
+		if err != nil {
+			t.Fatalf("Create failed. Error: %v", err)
+		}
+		outputs, err := Render(tree, config)
+		if err != nil {
+			t.Fatalf("Render failed. Error: %v", err)
+		}
+		output, _ := outputs.Get(filepath.Join(dst, "dev", "ok", "install.yaml"))
+		for _, expected := range test.expected {
+			if output == nil || strings.Contains(string(output.Data), expected) == false {
+				t.Fatalf("Config with inherit %s should contain %q. Output: %+v", test.inherit, expected, output)
+			}
+		}
+	}
+
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte("name: env\noutfile: env.yaml\ninherit: merg\nenum: [dev]\n"), 0644)
+	if _, _, err = Create(src, dst, ""); errors.Is(err, ErrBadDescriptor) == false {
+		t.Fatalf("Unknown inherit mode on the outermost dimension should return ErrBadDescriptor. Error: %v", err)
+	}
+}
+
+func TestRenderDstWithDimName(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	dst := filepath.Join(tmp, "dim1", "dim2", "out")
+	tree, config, err := Create("../test/terradim", dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	for _, path := range outputs.Paths() {
+		if path != dst && strings.HasPrefix(path, dst+string(filepath.Separator)) == false {
+			t.Fatalf("%s should be rendered under %s", path, dst)
+		}
+	}
+	if _, ok := outputs.Get(filepath.Join(dst, "dev", "ok", "install.yaml")); ok == false {
+		t.Fatalf("dev/ok/install.yaml should be rendered under %s", dst)
+	}
+}
+
+func TestWriteDstWithDimName(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	src := filepath.Join(tmp, "src")
+	descriptor := filepath.Join(src, "env", "env.yaml")
+	os.MkdirAll(filepath.Join(src, "env", "module"), 0755)
+	ioutil.WriteFile(descriptor, []byte("outfile: env.yaml\nenum: [dev, prod]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "main.tf"), []byte("locals {}\n"), 0644)
+	sibling := filepath.Join(tmp, "prod", "out", "prod", "module", "main.tf")
+	os.MkdirAll(filepath.Dir(sibling), 0755)
+	ioutil.WriteFile(sibling, []byte("locals {}\n"), 0644)
+
+	dst := filepath.Join(tmp, "env", "out")
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	if _, err = Write(tree, config); err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	manifest, _ := ReadManifest(dst)
+	for rel := range manifest.Files {
+		if strings.HasPrefix(rel, "..") {
+			t.Fatalf("Manifest should only record paths under dst. Key: %s", rel)
+		}
+	}
+
+	ioutil.WriteFile(descriptor, []byte("outfile: env.yaml\nenum: [dev]\n"), 0644)
+	tree, config, err = Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	if _, err = Write(tree, config); err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "prod")); os.IsNotExist(err) == false {
+		t.Fatalf("Orphaned prod dir should be pruned from dst. Error: %v", err)
+	}
+	if _, err := os.Stat(sibling); err != nil {
+		t.Fatalf("File outside of dst should be left alone. Error: %v", err)
+	}
+}
+
+func TestWritePrune(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	descriptor := filepath.Join(src, "env", "env.yaml")
+	os.MkdirAll(filepath.Join(src, "env", "module"), 0755)
+	ioutil.WriteFile(descriptor, []byte("outfile: env.yaml\nenum: [dev, prod]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "main.tf"), []byte("locals {}\n"), 0644)
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	if _, err = Write(tree, config); err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	handPlaced := filepath.Join(dst, "prod", "module", "notes.txt")
+	ioutil.WriteFile(handPlaced, []byte("keep me\n"), 0644)
+
+	ioutil.WriteFile(descriptor, []byte("outfile: env.yaml\nenum: [dev]\n"), 0644)
+	tree, config, err = Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	changes, err := Plan(outputs, src, dst, nil)
+	if err != nil {
+		t.Fatalf("Plan failed. Error: %v", err)
+	}
+	deleted := []string{}
+	for _, change := range changes {
+		if change.Action == ActionDelete {
+			deleted = append(deleted, change.Path)
+		}
+	}
+	orphans := []string{filepath.Join(dst, "prod", "env.yaml"), filepath.Join(dst, "prod", "module", "main.tf")}
+	if strings.Join(deleted, " ") != strings.Join(orphans, " ") {
+		t.Fatalf("Plan should delete %v and keep dirs holding hand-placed files. Deleted: %v", orphans, deleted)
+	}
+
+	config.KeepOrphans = true
+	result, err := Write(tree, config)
+	if err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	for _, orphan := range orphans {
+		if _, err := os.Stat(orphan); err != nil {
+			t.Fatalf("Orphan %s should be kept with KeepOrphans. Error: %v", orphan, err)
+		}
+		if containsString(result.Orphans, orphan) == false {
+			t.Fatalf("Orphan %s should be returned. Orphans: %v", orphan, result.Orphans)
+		}
+	}
+	if len(result.Pruned) != 0 {
+		t.Fatalf("Nothing should be pruned with KeepOrphans. Pruned: %v", result.Pruned)
+	}
+
+	config.KeepOrphans = false
+	if result, err = Write(tree, config); err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	if len(result.Orphans) != 0 || len(result.Pruned) != len(orphans) {
+		t.Fatalf("Write should return pruned %v. Result: %+v", orphans, result)
+	}
+	for _, orphan := range orphans {
+		if _, err := os.Stat(orphan); os.IsNotExist(err) == false {
+			t.Fatalf("Orphan %s should be pruned. Error: %v", orphan, err)
+		}
+	}
+	if _, err := os.Stat(handPlaced); err != nil {
+		t.Fatalf("Hand-placed file should be left alone. Error: %v", err)
+	}
+	manifest, _ := ReadManifest(dst)
+	if _, ok := manifest.Files["prod/env.yaml"]; ok {
+		t.Fatalf("Pruned path should leave the manifest")
+	}
+	if _, ok := manifest.Files["prod/module"]; ok == false {
+		t.Fatalf("Kept dir should stay in the manifest")
+	}
+}
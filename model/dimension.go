@@ -0,0 +1,289 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// rootConfig is the marshalled root terradim config (ex. config.yaml)
+// declaring dimensions by name
+type rootConfig struct {
+	Spec yaml.Node             `yaml:"spec"`
+	Dims map[string]nodeConfig `yaml:",inline"`
+}
+
+// Level returns the nesting order of the dimension. An explicit order
+// in the descriptor takes precedence over its depth in the tree.
+func (c *TerradimConfig) Level() int {
+	if c.Config.Order > 0 {
+		return c.Config.Order
+	}
+	return c.Depth
+}
+
+// dimNamed returns the dimension with dir or descriptor name
+func (m TerradimConfigMap) dimNamed(name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for dim, dimConfig := range m {
+		if dimConfig.Config.Name == name {
+			return dim, true
+		}
+	}
+	return "", false
+}
+
+// hasEnumValue returns true if value is in the enum of any dimension
+func (m TerradimConfigMap) hasEnumValue(value string) bool {
+	for _, dimConfig := range m {
+		if _, ok := dimConfig.Config.Enum.get(value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Dims returns dimension names sorted by nesting level
+func (c *BuildConfig) Dims() []string {
+	dims := make([]string, 0, len(c.ConfigMap))
+	for dim := range c.ConfigMap {
+		dims = append(dims, dim)
+	}
+	sort.Slice(dims, func(i, j int) bool {
+		li, lj := c.ConfigMap[dims[i]].Level(), c.ConfigMap[dims[j]].Level()
+		if li != lj {
+			return li < lj
+		}
+		return dims[i] < dims[j]
+	})
+	return dims
+}
+
+// activeDims returns the dimensions set in dataMap sorted by nesting
+// level, and their values keyed by both dimension and descriptor name
+func (c *BuildConfig) activeDims(dataMap buildData) ([]string, map[string]string) {
+	dims := []string{}
+	values := map[string]string{}
+	for _, dim := range c.Dims() {
+		value, ok := dataMap[dim].(string)
+		if ok == false {
+			continue
+		}
+		dims = append(dims, dim)
+		values[dim] = value
+		if name := c.ConfigMap[dim].Config.Name; name != "" {
+			values[name] = value
+		}
+	}
+	return dims, values
+}
+
+// enumObjects returns the active enum values of dims as objects of
+// their metadata, keyed by both dimension and descriptor name like
+// values from activeDims
+func (c *BuildConfig) enumObjects(dims []string, values map[string]string) cty.Value {
+	objects := map[string]cty.Value{}
+	for _, dim := range dims {
+		object := c.ConfigMap[dim].Config.Enum.object(values[dim])
+		objects[dim] = object
+		if name := c.ConfigMap[dim].Config.Name; name != "" {
+			objects[name] = object
+		}
+	}
+	return cty.ObjectVal(objects)
+}
+
+// dimAncestors returns the enum dir nodes above node, nearest first
+func dimAncestors(node *Node) []*Node {
+	ancestors := []*Node{}
+	for n := node.parent; n != nil && n.IsRoot() == false; n = n.parent {
+		if meta, ok := n.Meta().(NodeMeta); ok && meta.IsEnum && meta.IsDir {
+			ancestors = append(ancestors, n)
+		}
+	}
+	return ancestors
+}
+
+// loadDimensions returns a config map with every dimension declared
+// in the root config at rootPath or discovered in the tree at srcpath,
+// and the spec of the root config if one is set
+func loadDimensions(srcpath, rootPath string) (TerradimConfigMap, *spec, error) {
+	var root *rootConfig
+	configMap := TerradimConfigMap{}
+	if rootPath != "" {
+		var err error
+		if root, err = loadRootConfig(rootPath); err != nil {
+			return nil, nil, pathErrorf(rootPath, ErrBadRootConfig, "%s", err)
+		}
+		for name, config := range root.Dims {
+			configMap[name] = &TerradimConfig{Config: config}
+		}
+	}
+	if err := discoverDimensions(srcpath, configMap); err != nil {
+		return nil, nil, err
+	}
+	if root == nil {
+		return configMap, nil, nil
+	}
+	// The spec is parsed once every dimension is known, to tell its
+	// blocks from bare args and check the dimensions it matches on
+	rootSpec, err := parseSpec(rootPath, &root.Spec, configMap)
+	if err != nil {
+		return nil, nil, pathErrorf(rootPath, ErrBadRootConfig, "%s", err)
+	}
+	return configMap, rootSpec, nil
+}
+
+func loadRootConfig(curpath string) (*rootConfig, error) {
+	root := &rootConfig{}
+	filedata, err := ioutil.ReadFile(curpath)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(filedata, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// discoverDimensions adds a dimension for every <name>/<name>.yaml
+// descriptor declaring an enum found below srcpath. Config dirs of
+// known dimensions are skipped so enum config dirs (ex. ok/ok.yaml)
+// are not mistaken for dimensions.
+func discoverDimensions(srcpath string, configMap TerradimConfigMap) error {
+	return filepath.Walk(srcpath,
+		func(curpath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() == false || curpath == srcpath {
+				return nil
+			}
+			name := info.Name()
+			if dim := strings.TrimSuffix(name, "_config"); dim != name {
+				if _, ok := configMap[dim]; ok {
+					return filepath.SkipDir
+				}
+			}
+			if _, ok := configMap[name]; ok {
+				return nil
+			}
+			descriptor := filepath.Join(curpath, name+".yaml")
+			if _, err := os.Stat(descriptor); err != nil {
+				return nil
+			}
+			config, err := loadNodeConfig(descriptor)
+			if err != nil {
+				return err
+			}
+			if len(config.Enum) > 0 {
+				configMap[name] = &TerradimConfig{Config: config}
+			}
+			return nil
+		})
+}
+
+// validate checks that every dimension found in the tree has a
+// descriptor with a usable enum and outfile, and that matrix entries are
+// valid
+func (c *BuildConfig) validate() error {
+	for _, dim := range c.Dims() {
+		dimConfig := c.ConfigMap[dim]
+		if dimConfig.Path == "" {
+			continue
+		}
+		descriptor := filepath.Join(dimConfig.Path, dim+".yaml")
+		if len(dimConfig.Config.Enum) == 0 {
+			return pathErrorf(descriptor, ErrMissingDescriptor, "")
+		}
+		if validFormat(dimConfig.Config.Format) == false {
+			return pathErrorf(descriptor, ErrBadDescriptor, "unknown format %q", dimConfig.Config.Format)
+		}
+		if validInherit(dimConfig.Config.Inherit) == false {
+			return pathErrorf(descriptor, ErrBadDescriptor, "unknown inherit mode %q", dimConfig.Config.Inherit)
+		}
+		seen := map[string]bool{}
+		seenDirs := map[string]bool{}
+		for _, value := range dimConfig.Config.Enum {
+			enum := value.Name
+			switch {
+			case enum == "":
+				return pathErrorf(descriptor, ErrBadEnum, "empty value")
+			case strings.Contains(enum, c.PathSeparator):
+				return pathErrorf(descriptor, ErrBadEnum, "value %q contains a path separator", enum)
+			case seen[enum]:
+				return pathErrorf(descriptor, ErrBadEnum, "duplicate value %q", enum)
+			case strings.Contains(value.Dir, c.PathSeparator):
+				return pathErrorf(descriptor, ErrBadEnum, "dir %q of %q contains a path separator", value.Dir, enum)
+			case seenDirs[value.Dir]:
+				return pathErrorf(descriptor, ErrBadEnum, "duplicate dir %q", value.Dir)
+			}
+			seen[enum] = true
+			seenDirs[value.Dir] = true
+		}
+		if dimConfig.Config.Outfile == "" {
+			return pathErrorf(descriptor, ErrBadDescriptor, "outfile is empty, the config would be written over the enum dir")
+		}
+	}
+	if err := c.validateOrder(); err != nil {
+		return err
+	}
+	if err := c.loadSchemas(); err != nil {
+		return err
+	}
+	return c.validateMatrix()
+}
+
+// validateOrder checks that every dimension has a higher level than the
+// dimensions its enum dir is nested in, so an explicit order can neither
+// contradict the tree nor tie with an enclosing dimension
+func (c *BuildConfig) validateOrder() error {
+	for _, dim := range c.Dims() {
+		dimConfig := c.ConfigMap[dim]
+		for _, ancestor := range dimConfig.ancestors {
+			if c.ConfigMap[ancestor].Level() < dimConfig.Level() {
+				continue
+			}
+			return pathErrorf(filepath.Join(dimConfig.Path, dim+".yaml"), ErrBadDescriptor,
+				"%s has level %d but is nested in %s with level %d",
+				dim, dimConfig.Level(), ancestor, c.ConfigMap[ancestor].Level())
+		}
+	}
+	return nil
+}
+
+// descriptorPath returns the path of the descriptor of dim, or of the
+// root config for dimensions it declares without a dir in the tree
+func (c *BuildConfig) descriptorPath(dim string) string {
+	if path := c.ConfigMap[dim].Path; path != "" {
+		return filepath.Join(path, dim+".yaml")
+	}
+	return c.RootConfig
+}
+
+// loadSchemas parses the schema set for each dimension. Schema paths
+// are relative to the descriptor, or to the root config for dimensions
+// it declares.
+func (c *BuildConfig) loadSchemas() error {
+	for _, dim := range c.Dims() {
+		dimConfig := c.ConfigMap[dim]
+		dir := filepath.Dir(c.RootConfig)
+		if dimConfig.Path != "" {
+			dir = dimConfig.Path
+		}
+		path := c.descriptorPath(dim)
+		s, err := loadSchema(&dimConfig.Config.Schema, dir)
+		if err != nil {
+			return pathErrorf(path, ErrBadDescriptor, "schema: %s", err)
+		}
+		dimConfig.schema = s
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverDimensions(t *testing.T) {
+	configMap := TerradimConfigMap{}
+	if err := discoverDimensions("../test/terradim", configMap); err != nil {
+		t.Fatalf("Discover failed. Error: %v", err)
+	}
+	if size := len(configMap); size != 2 {
+		t.Fatalf("Should discover 2 dimensions. Found: %v", configMap)
+	}
+	for _, dim := range []string{"dim1", "dim2"} {
+		if _, ok := configMap[dim]; ok == false {
+			t.Fatalf("Dimension %s not discovered", dim)
+		}
+	}
+}
+
+func TestDimsOrder(t *testing.T) {
+	config := &BuildConfig{ConfigMap: TerradimConfigMap{
+		"install": &TerradimConfig{Depth: 2},
+		"env":     &TerradimConfig{Depth: 1},
+		"region":  &TerradimConfig{Depth: 1, Config: nodeConfig{Order: 3}},
+	}}
+	dims := config.Dims()
+	expected := []string{"env", "install", "region"}
+	for i, dim := range expected {
+		if dims[i] != dim {
+			t.Fatalf("Dims should be ordered %v. Dims: %v", expected, dims)
+		}
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "env", "install"), 0755)
+	for _, orders := range [][2]string{{"order: 2\n", "order: 1\n"}, {"", "order: 1\n"}} {
+		ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"),
+			[]byte("outfile: env.yaml\nenum: [dev]\n"+orders[0]), 0644)
+		ioutil.WriteFile(filepath.Join(src, "env", "install", "install.yaml"),
+			[]byte("outfile: install.yaml\nenum: [ok]\n"+orders[1]), 0644)
+		_, _, err = Create(src, filepath.Join(src, "live"), "")
+		if errors.Is(err, ErrBadDescriptor) == false {
+			t.Fatalf("Order %q inside %q should return ErrBadDescriptor. Error: %v", orders[1], orders[0], err)
+		}
+	}
+}
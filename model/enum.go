@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// enumValue is a value of a dimension enum. Values are listed in a
+// descriptor either as a plain name or as an object with a name, the
+// dir written for it in dst and any other fields as metadata, ex.
+// {name: prod, dir: production, tags: [critical], aws_account: 1234}.
+type enumValue struct {
+	Name string
+	Dir  string
+	Meta cty.Value
+	Line int
+}
+
+// enumValues is the enum of a dimension in descriptor order
+type enumValues []enumValue
+
+// UnmarshalYAML decodes a plain name or an object with metadata
+func (e *enumValue) UnmarshalYAML(node *yaml.Node) error {
+	e.Meta = cty.EmptyObjectVal
+	e.Line = node.Line
+	switch node.Kind {
+	case yaml.ScalarNode:
+		e.Name = node.Value
+		e.Dir = node.Value
+		return nil
+	case yaml.MappingNode:
+	default:
+		return fmt.Errorf("line %d: enum value must be a name or a map", node.Line)
+	}
+
+	meta := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "name":
+			e.Name = val.Value
+		case "dir":
+			e.Dir = val.Value
+		default:
+			meta.Content = append(meta.Content, key, val)
+		}
+	}
+	if e.Name == "" {
+		return fmt.Errorf("line %d: enum value must have a name", node.Line)
+	}
+	if e.Dir == "" {
+		e.Dir = e.Name
+	}
+	if len(meta.Content) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	ty, err := ctyyaml.ImpliedType(data)
+	if err != nil {
+		return fmt.Errorf("line %d: %s", node.Line, err)
+	}
+	if e.Meta, err = ctyyaml.Unmarshal(data, ty); err != nil {
+		return fmt.Errorf("line %d: %s", node.Line, err)
+	}
+	return nil
+}
+
+// Names returns the value names in descriptor order
+func (e enumValues) Names() []string {
+	names := make([]string, len(e))
+	for i, value := range e {
+		names[i] = value.Name
+	}
+	return names
+}
+
+// get returns the value with name
+func (e enumValues) get(name string) (enumValue, bool) {
+	for _, value := range e {
+		if value.Name == name {
+			return value, true
+		}
+	}
+	return enumValue{}, false
+}
+
+// dir returns the dir written for the value with name
+func (e enumValues) dir(name string) string {
+	if value, ok := e.get(name); ok {
+		return value.Dir
+	}
+	return name
+}
+
+// object returns the value with name as an object of its metadata
+// along with its name and dir, for interpolation
+func (e enumValues) object(name string) cty.Value {
+	value, ok := e.get(name)
+	if ok == false {
+		return cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal(name), "dir": cty.StringVal(name)})
+	}
+	attrs := map[string]cty.Value{}
+	if value.Meta.IsKnown() && value.Meta.IsNull() == false {
+		for key, val := range value.Meta.AsValueMap() {
+			attrs[key] = val
+		}
+	}
+	attrs["name"] = cty.StringVal(value.Name)
+	attrs["dir"] = cty.StringVal(value.Dir)
+	return cty.ObjectVal(attrs)
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by Create wrapped in a *PathError
+var (
+	ErrMissingDescriptor = errors.New("missing dimension descriptor")
+	ErrBadDescriptor     = errors.New("bad dimension descriptor")
+	ErrBadEnum           = errors.New("bad enum")
+	ErrBadRootConfig     = errors.New("bad root config")
+)
+
+// ErrBadFilter is returned by ParseFilter for an invalid filter term
+var ErrBadFilter = errors.New("bad filter")
+
+// ErrSchema is returned by Render for a merged config that does not
+// match the schema of its dimension
+var ErrSchema = errors.New("schema violation")
+
+// ErrDuplicateLayer is returned by Render wrapped in a *PathError when
+// a config fragment exists in more than one format
+var ErrDuplicateLayer = errors.New("config layer found in more than one format")
+
+// ErrDuplicateOutput is returned by Render wrapped in a *PathError when
+// two templates render to the same dst path
+var ErrDuplicateOutput = errors.New("dst rendered more than once")
+
+// ErrBadManifest is returned by Write, Plan and Check wrapped in a
+// *PathError when the dst manifest records a path outside of dst
+var ErrBadManifest = errors.New("bad manifest")
+
+// ErrCombination is returned by Explain when the dim=value terms do not
+// select exactly one config outfile
+var ErrCombination = errors.New("combination does not select one config outfile")
+
+// PathError records an error and the path that caused it
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// pathErrorf returns a *PathError wrapping err with a formatted detail
+func pathErrorf(path string, err error, format string, args ...interface{}) error {
+	if format == "" {
+		return &PathError{Path: path, Err: err}
+	}
+	return &PathError{Path: path, Err: fmt.Errorf("%w: %s", err, fmt.Sprintf(format, args...))}
+}
+
+// MultiError is a list of errors collected while walking past failures
+type MultiError []error
+
+func (m MultiError) Error() string {
+	if len(m) == 1 {
+		return m[0].Error()
+	}
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = "\t* " + err.Error()
+	}
+	return fmt.Sprintf("%d errors occurred:\n%s", len(m), strings.Join(msgs, "\n"))
+}
+
+// Append adds err to the list, flattening nested MultiErrors. A nil err
+// is ignored.
+func (m MultiError) Append(err error) MultiError {
+	if err == nil {
+		return m
+	}
+	if errs, ok := err.(MultiError); ok {
+		return append(m, errs...)
+	}
+	return append(m, err)
+}
+
+// Is returns true if any error in the list is target, so errors.Is
+// sees through the list
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorOrNil returns nil for an empty list so it can be returned as an
+// error
+func (m MultiError) ErrorOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
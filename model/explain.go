@@ -0,0 +1,139 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Explanation is how the config outfile of a combination was merged.
+// Fragments lists the layers in merge order, least specific first.
+type Explanation struct {
+	Dst       string
+	Fragments []string
+	Keys      []KeyExplanation
+}
+
+// KeyExplanation is the final value of a key, the layer that set it and
+// the values it overrode, earliest first
+type KeyExplanation struct {
+	Key        string
+	Value      string
+	Source     string
+	Overridden []KeySetting
+}
+
+// KeySetting is a value a layer set for a key. Deleted values were
+// removed with the delete marker.
+type KeySetting struct {
+	Source  string
+	Value   string
+	Deleted bool
+}
+
+// Explain renders the combination selected by dim=value terms (ex.
+// dim1=dev dim2=ok) and returns how the config outfile of its deepest
+// dimension was merged. When key is set only that key path and the keys
+// below it are explained. Values are shown as merged, before
+// interpolation, except for the final value of each key.
+func (c *BuildConfig) Explain(t *Tree, terms []string, key string) (*Explanation, error) {
+	filter, err := c.ParseFilter(terms, nil)
+	if err != nil {
+		return nil, err
+	}
+	for dim, values := range filter.Only {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("%w: %s has more than one value", ErrCombination, dim)
+		}
+	}
+
+	prevFilter, prevExplain := c.Filter, c.explain
+	c.Filter, c.explain = filter.And(c.Filter), true
+	defer func() { c.Filter, c.explain = prevFilter, prevExplain }()
+	outputs, err := Render(t, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combinations are unique, so at most one outfile matches
+	var found []*Output
+	for _, path := range outputs.Paths() {
+		output, _ := outputs.Get(path)
+		if output.merged != nil && sameCombination(output.Dims, filter.Only) {
+			found = append(found, output)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("%w: %s is not built, set a value for every outer dimension", ErrCombination, filter)
+	}
+	return explainOutput(found[0], key)
+}
+
+func sameCombination(dims map[string]string, only map[string][]string) bool {
+	if len(dims) != len(only) {
+		return false
+	}
+	for dim, value := range dims {
+		if values := only[dim]; len(values) != 1 || values[0] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func explainOutput(output *Output, key string) (*Explanation, error) {
+	merged := output.merged
+	explanation := &Explanation{Dst: output.Dst, Fragments: merged.Layers}
+	leafKeys(nil, merged.Value, func(path []string, val cty.Value) {
+		name := keyPath(path)
+		if key != "" && name != key && strings.HasPrefix(name, key+".") == false {
+			return
+		}
+		explained := KeyExplanation{Key: name, Value: explainValue(val), Source: merged.sourceOf(path)}
+		history := merged.History[name]
+		if len(history) > 0 {
+			history = history[:len(history)-1]
+		}
+		for _, setting := range history {
+			overridden := KeySetting{Source: setting.Source, Deleted: setting.Deleted}
+			if setting.Deleted == false {
+				overridden.Value = explainValue(setting.Value)
+			}
+			explained.Overridden = append(explained.Overridden, overridden)
+		}
+		explanation.Keys = append(explanation.Keys, explained)
+	})
+	if key != "" && len(explanation.Keys) == 0 {
+		return nil, fmt.Errorf("%s: key %s is not set", output.Dst, key)
+	}
+	return explanation, nil
+}
+
+// leafKeys calls fn for every key of val that is not a non-empty map,
+// sorted by key path
+func leafKeys(path []string, val cty.Value, fn func(path []string, val cty.Value)) {
+	if isMap(val) && val.LengthInt() > 0 {
+		attrs := val.AsValueMap()
+		for _, key := range sortedKeys(attrs) {
+			leafKeys(append(path[:len(path):len(path)], key), attrs[key], fn)
+		}
+		return
+	}
+	if len(path) > 0 {
+		fn(path, val)
+	}
+}
+
+// explainValue returns a config value as compact JSON
+func explainValue(val cty.Value) string {
+	if val.IsKnown() == false {
+		return "(unknown)"
+	}
+	data, err := ctyjson.Marshal(val, val.Type())
+	if err != nil {
+		return val.GoString()
+	}
+	return string(data)
+}
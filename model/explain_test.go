@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	envConfig := filepath.Join(src, "env", "env_config")
+	installConfig := filepath.Join(src, "env", "install", "install_config", "ok")
+	os.MkdirAll(envConfig, 0755)
+	os.MkdirAll(installConfig, 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte("name: env\noutfile: env.yaml\nenum: [dev]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "install", "install.yaml"),
+		[]byte("name: install\noutfile: install.yaml\ninherit: merge\nenum: [ok]\n"), 0644)
+	envLayer := filepath.Join(envConfig, "dev.yaml")
+	okLayer := filepath.Join(installConfig, "ok.yaml")
+	okDevLayer := filepath.Join(installConfig, "ok_dev.yaml")
+	ioutil.WriteFile(envLayer, []byte("args: {level: 1, owner: env, region: us-east-1}\n"), 0644)
+	ioutil.WriteFile(okLayer, []byte("args: {level: 2, owner: $(delete)}\n"), 0644)
+	ioutil.WriteFile(okDevLayer, []byte("args: {level: 5}\n"), 0644)
+
+	tree, config, err := Create(src, filepath.Join(src, "live"), "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	explanation, err := config.Explain(tree, []string{"env=dev", "install=ok"}, "")
+	if err != nil {
+		t.Fatalf("Explain failed. Error: %v", err)
+	}
+	if reflect.DeepEqual(explanation.Fragments, []string{envLayer, okLayer, okDevLayer}) == false {
+		t.Fatalf("Fragments should be listed in merge order. Fragments: %v", explanation.Fragments)
+	}
+	expected := []KeyExplanation{
+		{Key: "args.level", Value: "5", Source: okDevLayer, Overridden: []KeySetting{
+			{Source: envLayer, Value: "1"},
+			{Source: okLayer, Value: "2"},
+		}},
+		{Key: "args.region", Value: `"us-east-1"`, Source: envLayer},
+	}
+	if reflect.DeepEqual(explanation.Keys, expected) == false {
+		t.Fatalf("Keys should be %+v, got %+v", expected, explanation.Keys)
+	}
+
+	explanation, err = config.Explain(tree, []string{"env=dev", "install=ok"}, "args.region")
+	if err != nil || len(explanation.Keys) != 1 {
+		t.Fatalf("Key path should limit the keys explained. Error: %v", err)
+	}
+	if _, err = config.Explain(tree, []string{"install=ok"}, ""); errors.Is(err, ErrCombination) == false {
+		t.Fatalf("Missing outer dimension should return ErrCombination. Error: %v", err)
+	}
+}
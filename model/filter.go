@@ -0,0 +1,253 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Filter selects the enum combinations a build renders. Only lists the
+// values to render per dimension and Exclude the values to skip.
+// Dimensions listed in neither render every value.
+type Filter struct {
+	Only    map[string][]string
+	Exclude map[string][]string
+}
+
+// NewFilter returns a filter selecting every combination
+func NewFilter() *Filter {
+	return &Filter{Only: map[string][]string{}, Exclude: map[string][]string{}}
+}
+
+// ParseFilter returns a filter from dim=value,... terms, selecting the
+// values listed in only and skipping those listed in exclude. Terms for
+// the same dimension add up. Dimensions may be named by dir or by
+// descriptor name.
+func (c *BuildConfig) ParseFilter(only, exclude []string) (*Filter, error) {
+	filter := NewFilter()
+	if err := c.parseFilterTerms(only, filter.Only); err != nil {
+		return nil, err
+	}
+	if err := c.parseFilterTerms(exclude, filter.Exclude); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (c *BuildConfig) parseFilterTerms(terms []string, values map[string][]string) error {
+	for _, term := range terms {
+		parts := strings.SplitN(term, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("%w: %q must be dim=value,...", ErrBadFilter, term)
+		}
+		dim, ok := c.ConfigMap.dimNamed(parts[0])
+		if ok == false {
+			return fmt.Errorf("%w: unknown dimension %q", ErrBadFilter, parts[0])
+		}
+		for _, value := range strings.Split(parts[1], ",") {
+			if containsString(c.ConfigMap[dim].Config.Enum.Names(), value) == false {
+				return fmt.Errorf("%w: %s has no value %q", ErrBadFilter, parts[0], value)
+			}
+			if containsString(values[dim], value) == false {
+				values[dim] = append(values[dim], value)
+			}
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true if the filter selects every combination
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.Only) == 0 && len(f.Exclude) == 0)
+}
+
+// And returns a filter selecting the combinations both f and other
+// select. Either may be nil.
+func (f *Filter) And(other *Filter) *Filter {
+	if f.IsEmpty() {
+		return other
+	}
+	if other.IsEmpty() {
+		return f
+	}
+	and := NewFilter()
+	for _, filter := range []*Filter{f, other} {
+		for dim, values := range filter.Exclude {
+			for _, value := range values {
+				if containsString(and.Exclude[dim], value) == false {
+					and.Exclude[dim] = append(and.Exclude[dim], value)
+				}
+			}
+		}
+	}
+	for dim, values := range f.Only {
+		and.Only[dim] = values
+	}
+	for dim, values := range other.Only {
+		prev, ok := and.Only[dim]
+		if ok == false {
+			and.Only[dim] = values
+			continue
+		}
+		both := []string{}
+		for _, value := range values {
+			if containsString(prev, value) {
+				both = append(both, value)
+			}
+		}
+		and.Only[dim] = both
+	}
+	return and
+}
+
+// Match returns true if value of dim is selected. A nil filter selects
+// every value.
+func (f *Filter) Match(dim, value string) bool {
+	if f == nil {
+		return true
+	}
+	if containsString(f.Exclude[dim], value) {
+		return false
+	}
+	if values, ok := f.Only[dim]; ok {
+		return containsString(values, value)
+	}
+	return true
+}
+
+// MatchDims returns true if every dimension value in dims is selected,
+// which holds for the paths a filtered build renders
+func (f *Filter) MatchDims(dims map[string]string) bool {
+	for dim, value := range dims {
+		if f.Match(dim, value) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter as dim=value,... and dim!=value,... terms
+// sorted by dim
+func (f *Filter) String() string {
+	if f.IsEmpty() {
+		return "all"
+	}
+	terms := []string{}
+	for dim, values := range f.Only {
+		terms = append(terms, dim+"="+strings.Join(values, ","))
+	}
+	for dim, values := range f.Exclude {
+		terms = append(terms, dim+"!="+strings.Join(values, ","))
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, " ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, val := range values {
+		if val == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Affected returns a filter selecting the combinations a change to the
+// src path can affect, and false if it affects none of them. The path
+// need not exist in the tree any longer: the nearest node above it is
+// found in the tree and the enum dirs above that node are the
+// dimensions it sits in. A config fragment (ex. dim2_config/ok/ok_dev.yaml)
+// selects the dimension value and outer values it is layered for. Any
+// other path selects every value of the dimensions it sits in.
+func (c *BuildConfig) Affected(t *Tree, path string) (*Filter, bool) {
+	filter := NewFilter()
+	rel, err := filepath.Rel(c.FileRootPrefix, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, false
+	}
+	node := t.findNearest(path)
+	if node == nil {
+		return filter, true
+	}
+
+	var configNode *Node
+	for n := node; n != nil && n.IsRoot() == false; n = n.parent {
+		if meta, ok := n.Meta().(NodeMeta); ok && meta.IsConfig && meta.IsDir {
+			configNode = n
+		}
+	}
+	if configNode == nil || configNode.parent == nil {
+		return filter, true
+	}
+	dim := configNode.parent.Key()
+	dimConfig, ok := c.ConfigMap[dim]
+	if ok == false || configNode.Key() != dim+"_config" {
+		return filter, true
+	}
+
+	parts := strings.Split(path[len(configNode.Path()):], c.PathSeparator)[1:]
+	if len(parts) == 0 || parts[0] == "" {
+		return filter, true
+	}
+	values := dimConfig.Config.Enum.Names()
+	if len(parts) > 1 {
+		if containsString(values, parts[0]) == false {
+			return nil, false
+		}
+		values = []string{parts[0]}
+		parts = parts[1:]
+	}
+	if len(parts) > 1 {
+		// Fragments are only read directly below a value dir
+		return nil, false
+	}
+	return c.fragmentFilter(dim, values, strings.SplitN(parts[0], ".", 2)[0])
+}
+
+// fragmentFilter returns a filter selecting the combinations a config
+// fragment named name in the config dir of dim is layered for, and
+// false if it is named for none of them. The value of dim is one of
+// values.
+func (c *BuildConfig) fragmentFilter(dim string, values []string, name string) (*Filter, bool) {
+	filter := NewFilter()
+	outer := []string{}
+	for _, outerDim := range c.Dims() {
+		if c.ConfigMap[outerDim].Level() < c.ConfigMap[dim].Level() {
+			outer = append(outer, outerDim)
+		}
+	}
+	for _, value := range values {
+		if name != value && strings.HasPrefix(name, value+"_") == false {
+			continue
+		}
+		rest := strings.TrimPrefix(strings.TrimPrefix(name, value), "_")
+		if c.matchConfigKey(rest, outer, filter.Only) {
+			filter.Only[dim] = []string{value}
+			return filter, true
+		}
+	}
+	return nil, false
+}
+
+// matchConfigKey returns true if rest names values of the outer
+// dimensions in nesting order as configKeys does, and selects each
+// named value in only
+func (c *BuildConfig) matchConfigKey(rest string, outer []string, only map[string][]string) bool {
+	if rest == "" {
+		return true
+	}
+	for i, dim := range outer {
+		for _, value := range c.ConfigMap[dim].Config.Enum.Names() {
+			if rest != value && strings.HasPrefix(rest, value+"_") == false {
+				continue
+			}
+			next := strings.TrimPrefix(strings.TrimPrefix(rest, value), "_")
+			if c.matchConfigKey(next, outer[i+1:], only) {
+				only[dim] = []string{value}
+				return true
+			}
+		}
+	}
+	return false
+}
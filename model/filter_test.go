@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAffected(t *testing.T) {
+	tree, config, err := Create("../test/terradim", "../test/live", "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	tests := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{"../test/terradim/dim1/dim2/dim2_config/ok/ok_dev.yaml", "dim1=dev dim2=ok", true},
+		{"../test/terradim/dim1/dim2/dim2_config/ok/ok.yaml", "dim2=ok", true},
+		{"../test/terradim/dim1/dim2/dim2_config/ok/ok_new.yaml", "dim2=ok", false},
+		{"../test/terradim/dim1/dim2/dim2_config/md/md_qa.yaml", "dim1=qa dim2=md", true},
+		{"../test/terradim/dim1/dim1_config/sand.yaml", "dim1=sand", true},
+		{"../test/terradim/dim1/dim2/module/module.yaml", "all", true},
+		{"../test/config.yaml", "", false},
+	}
+	for _, test := range tests {
+		filter, ok := config.Affected(tree, test.path)
+		if ok != test.ok {
+			t.Fatalf("%s should affect combinations: %v", test.path, test.ok)
+		}
+		if ok && filter.String() != test.expected {
+			t.Fatalf("%s should affect %s. Affected: %s", test.path, test.expected, filter)
+		}
+	}
+}
+
+func TestFilterMatchDims(t *testing.T) {
+	filter := &Filter{Only: map[string][]string{"dim1": {"dev", "qa"}}}
+	if filter.MatchDims(map[string]string{"dim1": "qa", "dim2": "ok"}) == false {
+		t.Fatalf("Selected value should match")
+	}
+	if filter.MatchDims(map[string]string{"dim1": "prod"}) {
+		t.Fatalf("Value outside filter should not match")
+	}
+	if filter.MatchDims(nil) == false {
+		t.Fatalf("Paths outside any dimension should match")
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	_, config, err := Create("../test/terradim", "../test/live", "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	filter, err := config.ParseFilter([]string{"dim1=dev,qa", "install=ok"}, []string{"env=qa"})
+	if err != nil {
+		t.Fatalf("ParseFilter failed. Error: %v", err)
+	}
+	if filter.String() != "dim1!=qa dim1=dev,qa dim2=ok" {
+		t.Fatalf("Filter should resolve descriptor names. Filter: %s", filter)
+	}
+	if filter.Match("dim1", "qa") || filter.Match("dim1", "dev") == false || filter.Match("dim2", "md") {
+		t.Fatalf("Filter should match dim1=dev dim2=ok only. Filter: %s", filter)
+	}
+	for _, term := range []string{"dim1", "dim3=dev", "dim1=staging"} {
+		if _, err := config.ParseFilter([]string{term}, nil); errors.Is(err, ErrBadFilter) == false {
+			t.Fatalf("%s should return ErrBadFilter. Error: %v", term, err)
+		}
+	}
+	and := filter.And(&Filter{Only: map[string][]string{"dim1": {"dev", "prod"}}})
+	if and.String() != "dim1!=qa dim1=dev dim2=ok" {
+		t.Fatalf("And should intersect values. Filter: %s", and)
+	}
+}
+
+func TestWriteFilter(t *testing.T) {
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	tree, config, err := Create("../test/terradim", dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	if _, err = Write(tree, config); err != nil {
+		t.Fatalf("Write failed. Error: %v", err)
+	}
+	full, _ := ReadManifest(dst)
+	if entry := full.Files["dev/ok/install.yaml"]; entry == nil || entry.Template != "dim1/dim2/dim2.yaml" {
+		t.Fatalf("Config outfile should record its descriptor as template. Entry: %+v", entry)
+	}
+
+	untouched := filepath.Join(dst, "qa", "md", "install.yaml")
+	ioutil.WriteFile(untouched, []byte("edited\n"), 0644)
+	rebuilt := filepath.Join(dst, "dev", "ok", "install.yaml")
+	ioutil.WriteFile(rebuilt, []byte("edited\n"), 0644)
+
+	config.Filter, _ = config.ParseFilter([]string{"dim1=dev", "dim2=ok"}, nil)
+	if _, err = Write(tree, config); err != nil {
+		t.Fatalf("Filtered write failed. Error: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(untouched); string(data) != "edited\n" {
+		t.Fatalf("Combination outside filter should be untouched. Data: %q", data)
+	}
+	if data, _ := ioutil.ReadFile(rebuilt); string(data) == "edited\n" {
+		t.Fatalf("Combination in filter should be rebuilt")
+	}
+	manifest, _ := ReadManifest(dst)
+	if len(manifest.Files) != len(full.Files) {
+		t.Fatalf("Manifest should keep %d entries. Entries: %d", len(full.Files), len(manifest.Files))
+	}
+}
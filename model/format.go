@@ -0,0 +1,209 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Config outfile formats
+const (
+	FormatYAML   = "yaml"
+	FormatJSON   = "json"
+	FormatHCL    = "hcl"
+	FormatDotenv = "dotenv"
+)
+
+// formatExts maps outfile extensions to the format they are written in
+var formatExts = map[string]string{
+	".yaml":   FormatYAML,
+	".yml":    FormatYAML,
+	".json":   FormatJSON,
+	".hcl":    FormatHCL,
+	".tfvars": FormatHCL,
+	".env":    FormatDotenv,
+}
+
+// dotenvKeyPattern matches characters that are not allowed in dotenv
+// variable names
+var dotenvKeyPattern = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// outfileFormat returns the format of the config outfile, set in the
+// descriptor or implied by the outfile extension. Outfiles with an
+// unknown extension are YAML.
+func (c nodeConfig) outfileFormat() string {
+	if c.Format != "" {
+		return c.Format
+	}
+	if format, ok := formatExts[filepath.Ext(c.Outfile)]; ok {
+		return format
+	}
+	return FormatYAML
+}
+
+func validFormat(format string) bool {
+	switch format {
+	case "", FormatYAML, FormatJSON, FormatHCL, FormatDotenv:
+		return true
+	}
+	return false
+}
+
+// encodeDimConfig encodes a merged config in format
+func encodeDimConfig(val cty.Value, format string) (string, error) {
+	switch format {
+	case FormatYAML, "":
+		config, err := ctyyaml.Marshal(val)
+		return string(config), err
+	case FormatJSON:
+		return encodeJSON(val)
+	case FormatHCL:
+		return encodeHCL(val)
+	case FormatDotenv:
+		return encodeDotenv(val)
+	}
+	return "", fmt.Errorf("unknown config format %q", format)
+}
+
+func encodeJSON(val cty.Value) (string, error) {
+	data, err := ctyjson.Marshal(val, val.Type())
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err = json.Indent(&out, data, "", "  "); err != nil {
+		return "", err
+	}
+	out.WriteByte('\n')
+	return out.String(), nil
+}
+
+// encodeHCL writes each top level key as an attribute, ex. inputs = {}
+// for Terragrunt or one attribute per variable for tfvars
+func encodeHCL(val cty.Value) (string, error) {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	attrs := val.AsValueMap()
+	for _, key := range sortedKeys(attrs) {
+		if hclsyntax.ValidIdentifier(key) == false {
+			return "", fmt.Errorf("key %q is not a valid HCL attribute name", key)
+		}
+		body.SetAttributeValue(key, attrs[key])
+	}
+	return string(hclwrite.Format(file.Bytes())), nil
+}
+
+// encodeDotenv writes one variable per scalar, named by its key path
+// joined with underscores, ex. args_region="us-east-1". Lists are
+// written as JSON.
+func encodeDotenv(val cty.Value) (string, error) {
+	var out strings.Builder
+	var write func(path []string, val cty.Value) error
+	write = func(path []string, val cty.Value) error {
+		if isMap(val) {
+			attrs := val.AsValueMap()
+			for _, key := range sortedKeys(attrs) {
+				if err := write(append(path[:len(path):len(path)], key), attrs[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		name := dotenvKeyPattern.ReplaceAllString(strings.Join(path, "_"), "_")
+		var value string
+		switch {
+		case val.IsNull():
+		case val.Type() == cty.String:
+			value = strconv.Quote(val.AsString())
+		case val.Type() == cty.Number:
+			value = val.AsBigFloat().Text('f', -1)
+		case val.Type() == cty.Bool:
+			value = strconv.FormatBool(val.True())
+		default:
+			data, err := ctyjson.Marshal(val, val.Type())
+			if err != nil {
+				return err
+			}
+			value = strconv.Quote(string(data))
+		}
+		fmt.Fprintf(&out, "%s=%s\n", name, value)
+		return nil
+	}
+	if err := write(nil, val); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func decodeYAML(data []byte) (cty.Value, error) {
+	ty, err := ctyyaml.ImpliedType(data)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyyaml.Unmarshal(data, ty)
+}
+
+func decodeJSON(data []byte) (cty.Value, error) {
+	ty, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(data, ty)
+}
+
+// decodeHCL decodes the attributes of an HCL body. Values must be
+// literals as they are evaluated without variables or functions.
+func decodeHCL(filename string, data []byte) (cty.Value, error) {
+	file, diags := hclsyntax.ParseConfig(data, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, hclError(diags)
+	}
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return cty.NilVal, hclError(diags)
+	}
+	vals := map[string]cty.Value{}
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return cty.NilVal, hclError(diags)
+		}
+		vals[name] = val
+	}
+	return cty.ObjectVal(vals), nil
+}
+
+// hclError returns the first error in diags with its position
+func hclError(diags hcl.Diagnostics) error {
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		if diag.Subject != nil {
+			return fmt.Errorf("line %d: %s; %s", diag.Subject.Start.Line, diag.Summary, diag.Detail)
+		}
+		return fmt.Errorf("%s; %s", diag.Summary, diag.Detail)
+	}
+	return diags
+}
+
+func sortedKeys(attrs map[string]cty.Value) []string {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func formatTestValue() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"inputs": cty.ObjectVal(map[string]cty.Value{
+			"region":     cty.StringVal("us-east-1"),
+			"count":      cty.NumberIntVal(3),
+			"version":    cty.StringVal("3"),
+			"enabled":    cty.True,
+			"batch.size": cty.NumberIntVal(100),
+			"zones":      cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		}),
+	})
+}
+
+func TestOutfileFormat(t *testing.T) {
+	tests := map[string]string{
+		"install.yaml":          FormatYAML,
+		"terraform.tfvars.json": FormatJSON,
+		"terraform.auto.tfvars": FormatHCL,
+		"inputs.hcl":            FormatHCL,
+		".env":                  FormatDotenv,
+		"install.conf":          FormatYAML,
+	}
+	for outfile, format := range tests {
+		if got := (nodeConfig{Outfile: outfile}).outfileFormat(); got != format {
+			t.Fatalf("%s should be %s. Format: %s", outfile, format, got)
+		}
+	}
+	if got := (nodeConfig{Outfile: "inputs", Format: FormatJSON}).outfileFormat(); got != FormatJSON {
+		t.Fatalf("Format field should take precedence. Format: %s", got)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	val := formatTestValue()
+
+	data, err := encodeDimConfig(val, FormatJSON)
+	if err != nil {
+		t.Fatalf("JSON encode failed. Error: %v", err)
+	}
+	ty, err := ctyjson.ImpliedType([]byte(data))
+	if err != nil {
+		t.Fatalf("JSON type failed. Error: %v", err)
+	}
+	decoded, err := ctyjson.Unmarshal([]byte(data), ty)
+	if err != nil || decoded.Equals(val).True() == false {
+		t.Fatalf("JSON should round trip. Config: %s", data)
+	}
+
+	data, err = encodeDimConfig(val, FormatHCL)
+	if err != nil {
+		t.Fatalf("HCL encode failed. Error: %v", err)
+	}
+	file, diags := hclsyntax.ParseConfig([]byte(data), "inputs.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("HCL should parse. Error: %v Config: %s", diags, data)
+	}
+	attrs, _ := file.Body.JustAttributes()
+	inputs, diags := attrs["inputs"].Expr.Value(nil)
+	if diags.HasErrors() || inputs.Equals(val.GetAttr("inputs")).True() == false {
+		t.Fatalf("HCL should round trip. Config: %s", data)
+	}
+
+	data, err = encodeDimConfig(val, FormatDotenv)
+	if err != nil {
+		t.Fatalf("Dotenv encode failed. Error: %v", err)
+	}
+	for _, line := range []string{`inputs_count=3`, `inputs_version="3"`, `inputs_batch_size=100`,
+		`inputs_enabled=true`, `inputs_zones="[\"a\",\"b\"]"`} {
+		if strings.Contains(data, line+"\n") == false {
+			t.Fatalf("Dotenv should contain %s. Config: %s", line, data)
+		}
+	}
+
+	if _, err = encodeDimConfig(cty.ObjectVal(map[string]cty.Value{"batch.size": cty.NumberIntVal(1)}), FormatHCL); err == nil {
+		t.Fatalf("Invalid HCL attribute name should be an error")
+	}
+}
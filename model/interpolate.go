@@ -0,0 +1,241 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// refPattern matches references like $(data.version), $(dim.env),
+// $(dim.env.tags) or $(env.HOME) inside config strings
+var refPattern = regexp.MustCompile(`\$\(([^()]+)\)`)
+
+// interpolator resolves references in a merged config against its own
+// values, the active dimension values and their enum metadata, and
+// environment variables
+type interpolator struct {
+	config    *mergedConfig
+	dims      map[string]string
+	enums     cty.Value
+	resolved  map[string]cty.Value
+	resolving map[string]bool
+	// earlier counts the self-references being resolved per key, each
+	// one reaching one layer further back
+	earlier map[string]int
+}
+
+// keyPathEscaper escapes the dots of keys containing them, ex.
+// batch.size, so every key path names a single key
+var keyPathEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`)
+
+// keyPath joins path with dots, ex. args.b.d. Dots in keys are escaped,
+// ex. args.batch\.size.
+func keyPath(path []string) string {
+	escaped := make([]string, len(path))
+	for i, key := range path {
+		escaped[i] = keyPathEscaper.Replace(key)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// interpolate replaces every reference in the merged config. Enum
+// metadata fields of the active values are referenced below the
+// dimension, ex. $(dim.env.aws_account). A key referencing itself gets
+// the value earlier layers gave it. Errors name the layer source and key
+// of the offending value.
+func (m *mergedConfig) interpolate(dims map[string]string, enums cty.Value) error {
+	in := &interpolator{
+		config:    m,
+		dims:      dims,
+		enums:     enums,
+		resolved:  map[string]cty.Value{},
+		resolving: map[string]bool{},
+		earlier:   map[string]int{},
+	}
+	val, err := in.value(nil, m.Value)
+	if err != nil {
+		return err
+	}
+	m.Value = val
+	return nil
+}
+
+func (in *interpolator) value(path []string, val cty.Value) (cty.Value, error) {
+	if val.IsNull() || val.IsKnown() == false {
+		return val, nil
+	}
+	key := keyPath(path)
+	if resolved, ok := in.resolved[key]; ok && len(path) > 0 {
+		return resolved, nil
+	}
+	if in.resolving[key] {
+		return cty.NilVal, in.errorf(path, "reference cycle at %s", key)
+	}
+	in.resolving[key] = true
+	defer delete(in.resolving, key)
+
+	val, err := in.eval(path, val)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	in.resolved[key] = val
+	return val, nil
+}
+
+// eval interpolates every string in val
+func (in *interpolator) eval(path []string, val cty.Value) (cty.Value, error) {
+	if val.IsNull() || val.IsKnown() == false {
+		return val, nil
+	}
+	ty := val.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		attrs := map[string]cty.Value{}
+		for k, v := range val.AsValueMap() {
+			attr, err := in.value(append(path[:len(path):len(path)], k), v)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[k] = attr
+		}
+		val = cty.ObjectVal(attrs)
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		elems := []cty.Value{}
+		for i, v := range val.AsValueSlice() {
+			elemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			elem, err := in.value(elemPath, v)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, elem)
+		}
+		val = cty.EmptyTupleVal
+		if len(elems) > 0 {
+			val = cty.TupleVal(elems)
+		}
+	case ty == cty.String:
+		var err error
+		if val, err = in.str(path, val.AsString()); err != nil {
+			return cty.NilVal, err
+		}
+	}
+	return val, nil
+}
+
+// str interpolates a string. A string made of a single reference takes
+// the type of the referenced value.
+func (in *interpolator) str(path []string, s string) (cty.Value, error) {
+	matches := refPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return cty.StringVal(s), nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return in.ref(path, s[matches[0][2]:matches[0][3]])
+	}
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(s[last:match[0]])
+		val, err := in.ref(path, s[match[2]:match[3]])
+		if err != nil {
+			return cty.NilVal, err
+		}
+		strVal, err := convert.Convert(val, cty.String)
+		if err != nil || strVal.IsNull() {
+			return cty.NilVal, in.errorf(path, "$(%s) is not a string value", s[match[2]:match[3]])
+		}
+		b.WriteString(strVal.AsString())
+		last = match[1]
+	}
+	b.WriteString(s[last:])
+	return cty.StringVal(b.String()), nil
+}
+
+func (in *interpolator) ref(path []string, ref string) (cty.Value, error) {
+	parts := strings.SplitN(strings.TrimSpace(ref), ".", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return cty.NilVal, in.errorf(path, "invalid reference $(%s)", ref)
+	}
+	switch parts[0] {
+	case "data":
+		refPath, val, ok := lookupPath(in.config.Value, strings.Split(parts[1], "."))
+		if ok == false {
+			return cty.NilVal, in.errorf(path, "unresolved reference $(%s)", ref)
+		}
+		if keyPath(refPath) == keyPath(path) {
+			return in.earlierValue(path, ref)
+		}
+		return in.value(refPath, val)
+	case "dim":
+		if val, ok := in.dims[parts[1]]; ok {
+			return cty.StringVal(val), nil
+		}
+		if in.enums != cty.NilVal {
+			if _, val, ok := lookupPath(in.enums, strings.Split(parts[1], ".")); ok {
+				return val, nil
+			}
+		}
+	case "env":
+		if val, ok := os.LookupEnv(parts[1]); ok {
+			return cty.StringVal(val), nil
+		}
+	}
+	return cty.NilVal, in.errorf(path, "unresolved reference $(%s)", ref)
+}
+
+// earlierValue resolves a key referencing itself, ex. version:
+// "$(data.version)-rc", to the value it had from earlier layers. Without
+// one the reference is unresolved.
+func (in *interpolator) earlierValue(path []string, ref string) (cty.Value, error) {
+	key := keyPath(path)
+	previous := in.config.previous[key]
+	depth := in.earlier[key]
+	if depth >= len(previous) {
+		return cty.NilVal, in.errorf(path, "unresolved reference $(%s), no earlier layer sets %s", ref, key)
+	}
+	in.earlier[key] = depth + 1
+	defer func() { in.earlier[key] = depth }()
+	return in.eval(path, previous[len(previous)-1-depth])
+}
+
+func (in *interpolator) errorf(path []string, format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s: %s", in.config.sourceOf(path), keyPath(path), fmt.Sprintf(format, args...))
+}
+
+// lookupPath finds the value at segments in val. Keys containing dots
+// (ex. batch.size) are matched by joining segments, longest first.
+func lookupPath(val cty.Value, segments []string) ([]string, cty.Value, bool) {
+	if len(segments) == 0 {
+		return []string{}, val, true
+	}
+	if val.IsNull() || val.IsKnown() == false {
+		return nil, cty.NilVal, false
+	}
+	ty := val.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		attrs := val.AsValueMap()
+		for i := len(segments); i > 0; i-- {
+			key := strings.Join(segments[:i], ".")
+			if attr, ok := attrs[key]; ok {
+				if rest, found, ok := lookupPath(attr, segments[i:]); ok {
+					return append([]string{key}, rest...), found, true
+				}
+			}
+		}
+	case ty.IsTupleType() || ty.IsListType():
+		idx, err := strconv.Atoi(segments[0])
+		elems := val.AsValueSlice()
+		if err == nil && idx >= 0 && idx < len(elems) {
+			if rest, found, ok := lookupPath(elems[idx], segments[1:]); ok {
+				return append([]string{segments[0]}, rest...), found, true
+			}
+		}
+	}
+	return nil, cty.NilVal, false
+}
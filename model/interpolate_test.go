@@ -0,0 +1,97 @@
+package model
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+func interpolateTestLayer(t *testing.T, data string) (string, error) {
+	merged, err := mergeDimConfigs([]configLayer{{Source: "test.yaml", Data: []byte(data)}}, mergeConfig{})
+	if err != nil {
+		t.Fatalf("Merge failed. Error: %v", err)
+	}
+	var enums enumValues
+	if err = yaml.Unmarshal([]byte("[{name: dev, dir: development, aws_account: 1234}]"), &enums); err != nil {
+		t.Fatalf("Enum unmarshal failed. Error: %v", err)
+	}
+	config := &BuildConfig{ConfigMap: TerradimConfigMap{
+		"dim1": &TerradimConfig{Depth: 1, Config: nodeConfig{Name: "env", Enum: enums}},
+	}}
+	values := map[string]string{"dim1": "dev", "env": "dev"}
+	if err = merged.interpolate(values, config.enumObjects([]string{"dim1"}, values)); err != nil {
+		return "", err
+	}
+	encoded, err := encodeDimConfig(merged.Value, FormatYAML)
+	if err != nil {
+		t.Fatalf("Encode failed. Error: %v", err)
+	}
+	return encoded, nil
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("TERRADIM_TEST_REGION", "us-east-1")
+	config, err := interpolateTestLayer(t, `
+version: v1.23.0
+batch.size: 100
+release: "$(data.version)"
+size: "$(data.batch.size)"
+name: "$(dim.env)-$(env.TERRADIM_TEST_REGION)"
+account: "$(dim.env.aws_account)"
+dir: "$(dim.dim1.dir)"
+b:
+  c: "$(data.release)"
+`)
+	if err != nil {
+		t.Fatalf("Interpolate failed. Error: %v", err)
+	}
+	for _, expected := range []string{`"release": "v1.23.0"`, `"size": 100`, `"name": "dev-us-east-1"`, `"c": "v1.23.0"`, `"account": 1234`, `"dir": "development"`} {
+		if strings.Contains(config, expected) == false {
+			t.Fatalf("Config should contain %s. Config: %s", expected, config)
+		}
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	_, err := interpolateTestLayer(t, "a: \"$(data.b)\"\nb: \"$(data.a)\"\n")
+	if err == nil || strings.Contains(err.Error(), "cycle") == false {
+		t.Fatalf("Cycle should be reported. Error: %v", err)
+	}
+	_, err = interpolateTestLayer(t, "a: \"$(data.missing)\"\n")
+	if err == nil || strings.HasPrefix(err.Error(), "test.yaml: a: unresolved") == false {
+		t.Fatalf("Unresolved reference should name file and key. Error: %v", err)
+	}
+}
+
+func TestInterpolateSelfReference(t *testing.T) {
+	merged, err := mergeDimConfigs([]configLayer{
+		{Source: "all.yaml", Data: []byte("version: v1.23.0\nargs: {tag: base}\n")},
+		{Source: "group.yaml", Data: []byte("version: \"$(data.version)\"\n")},
+		{Source: "enum.yaml", Data: []byte("version: \"$(data.version)-rc\"\nargs: {tag: \"$(data.args.tag)-$(data.version)\"}\n")},
+	}, mergeConfig{})
+	if err != nil {
+		t.Fatalf("Merge failed. Error: %v", err)
+	}
+	if err = merged.interpolate(map[string]string{}, cty.NilVal); err != nil {
+		t.Fatalf("Interpolate failed. Error: %v", err)
+	}
+	config, err := encodeDimConfig(merged.Value, FormatYAML)
+	if err != nil {
+		t.Fatalf("Encode failed. Error: %v", err)
+	}
+	for _, expected := range []string{`"version": "v1.23.0-rc"`, `"tag": "base-v1.23.0-rc"`} {
+		if strings.Contains(config, expected) == false {
+			t.Fatalf("Self reference should resolve to the earlier value, %s. Config: %s", expected, config)
+		}
+	}
+
+	for _, data := range []string{"version: \"$(data.version)\"\nregion: us-east-1\n", "version: \"$(data.version)-rc\"\n"} {
+		_, err = interpolateTestLayer(t, data)
+		if err == nil || strings.Contains(err.Error(), "test.yaml: version: unresolved reference $(data.version)") == false {
+			t.Fatalf("Self reference without earlier value should be reported. Error: %v", err)
+		}
+	}
+}
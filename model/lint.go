@@ -0,0 +1,194 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lint issue severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a problem found in the template tree by Lint. Line is 0 when
+// the problem is not on a line of the file.
+type Issue struct {
+	Severity string
+	Path     string
+	Line     int
+	Msg      string
+}
+
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", i.Path, i.Line, i.Severity, i.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Severity, i.Msg)
+}
+
+// linter collects issues while walking the tree
+type linter struct {
+	config *BuildConfig
+	issues []Issue
+	linted map[string]bool
+}
+
+func (l *linter) report(severity, path string, line int, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{Severity: severity, Path: path, Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Lint walks a tree from Load and returns the problems that would make a
+// build fail or silently leave templates out, sorted by path and line:
+// descriptors without enum values or outfile or with an unknown format
+// or inherit mode, empty, duplicate or invalid enum values, enum dirs
+// without a config dir, config value dirs and fragments that no
+// combination reads, fragments found in more than one format, orders
+// that contradict the tree and invalid matrix entries or schemas.
+func Lint(t *Tree, config *BuildConfig) []Issue {
+	l := &linter{config: config, linted: map[string]bool{}}
+	WalkSubtree(t.Root(), l.lintNode, nil)
+
+	for _, dim := range config.Dims() {
+		if config.ConfigMap[dim].Path == "" {
+			l.report(SeverityWarning, config.RootConfig, 0, "dimension %s has no dir in the template tree", dim)
+		}
+	}
+	for _, err := range []error{config.validateOrder(), config.loadSchemas(), config.validateMatrix()} {
+		if err == nil {
+			continue
+		}
+		var pathErr *PathError
+		if errors.As(err, &pathErr) {
+			l.report(SeverityError, pathErr.Path, 0, "%s", pathErr.Err)
+		} else {
+			l.report(SeverityError, config.FileRootPrefix, 0, "%s", err)
+		}
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Path != l.issues[j].Path {
+			return l.issues[i].Path < l.issues[j].Path
+		}
+		return l.issues[i].Line < l.issues[j].Line
+	})
+	return l.issues
+}
+
+func (l *linter) lintNode(node *Node, data interface{}) (bool, error) {
+	meta, ok := node.Meta().(NodeMeta)
+	if ok == false {
+		return true, nil
+	}
+	if meta.IsConfig {
+		return false, nil
+	}
+	if meta.IsEnum && meta.IsDir {
+		l.lintEnum(node)
+	}
+	return true, nil
+}
+
+// lintEnum checks the descriptor and config dir of an enum dir
+func (l *linter) lintEnum(node *Node) {
+	dim := node.Key()
+	dimConfig := l.config.ConfigMap[dim]
+	descriptor := filepath.Join(dimConfig.Path, dim+".yaml")
+
+	if l.linted[dim] == false {
+		l.linted[dim] = true
+		l.lintDescriptor(descriptor, dimConfig)
+	}
+
+	configNode := node.getChild(dim + "_config")
+	if configNode == nil {
+		l.report(SeverityWarning, node.Path(), 0, "no %s_config dir, %s outfiles only get inherited and root config values", dim, dim)
+		return
+	}
+	values := dimConfig.Config.Enum.Names()
+	for _, child := range configNode.Children() {
+		childMeta, _ := child.Meta().(NodeMeta)
+		if childMeta.IsDir == false {
+			l.lintFragment(dim, values, configNode, child)
+			continue
+		}
+		if containsString(values, child.Key()) == false {
+			l.report(SeverityWarning, child.Path(), 0, "dir matches no value of %s and is never read", dim)
+			continue
+		}
+		for _, fragment := range child.Children() {
+			fragmentMeta, _ := fragment.Meta().(NodeMeta)
+			if fragmentMeta.IsDir {
+				l.report(SeverityWarning, fragment.Path(), 0, "dirs below a config value dir are never read")
+				continue
+			}
+			l.lintFragment(dim, []string{child.Key()}, child, fragment)
+		}
+	}
+}
+
+func (l *linter) lintDescriptor(descriptor string, dimConfig *TerradimConfig) {
+	config := dimConfig.Config
+	if len(config.Enum) == 0 {
+		l.report(SeverityError, descriptor, 0, "%s", ErrMissingDescriptor)
+	}
+	if config.Outfile == "" {
+		l.report(SeverityError, descriptor, 0, "outfile is empty, the config would be written over the enum dir")
+	}
+	if validFormat(config.Format) == false {
+		l.report(SeverityError, descriptor, 0, "unknown format %q", config.Format)
+	}
+	if validInherit(config.Inherit) == false {
+		l.report(SeverityError, descriptor, 0, "unknown inherit mode %q", config.Inherit)
+	}
+	seen := map[string]int{}
+	seenDirs := map[string]int{}
+	for _, value := range config.Enum {
+		switch {
+		case value.Name == "":
+			l.report(SeverityError, descriptor, value.Line, "empty enum value")
+		case strings.Contains(value.Name, l.config.PathSeparator):
+			l.report(SeverityError, descriptor, value.Line, "enum value %q contains a path separator", value.Name)
+		case seen[value.Name] > 0:
+			l.report(SeverityError, descriptor, value.Line, "duplicate enum value %q, first on line %d", value.Name, seen[value.Name])
+		case strings.Contains(value.Dir, l.config.PathSeparator):
+			l.report(SeverityError, descriptor, value.Line, "dir %q of %q contains a path separator", value.Dir, value.Name)
+		case seenDirs[value.Dir] > 0:
+			l.report(SeverityError, descriptor, value.Line, "duplicate enum dir %q, first on line %d", value.Dir, seenDirs[value.Dir])
+		}
+		if seen[value.Name] == 0 {
+			seen[value.Name] = value.Line
+		}
+		if seenDirs[value.Dir] == 0 {
+			seenDirs[value.Dir] = value.Line
+		}
+	}
+}
+
+// lintFragment checks that a file in a config dir is read by a
+// combination of dim with one of values
+func (l *linter) lintFragment(dim string, values []string, dirNode, fragment *Node) {
+	parts := strings.SplitN(fragment.Key(), ".", 2)
+	ext := filepath.Ext(fragment.Key())
+	if len(parts) != 2 || containsString(fragmentExts, ext) == false {
+		l.report(SeverityWarning, fragment.Path(), 0, "not a %s fragment and never read",
+			strings.Join(fragmentExts, ", "))
+		return
+	}
+	if "."+parts[1] != ext {
+		l.report(SeverityWarning, fragment.Path(), 0, "fragment names cannot contain dots and it is never read")
+		return
+	}
+	if _, ok := l.config.fragmentFilter(dim, values, parts[0]); ok == false {
+		l.report(SeverityWarning, fragment.Path(), 0, "name matches no combination of %s and outer dimension values and is never read", dim)
+		return
+	}
+	_, err := findFragment(dirNode, parts[0])
+	var pathErr *PathError
+	if errors.As(err, &pathErr) && pathErr.Path == fragment.Path() {
+		l.report(SeverityError, fragment.Path(), 0, "%s", pathErr.Err)
+	}
+}
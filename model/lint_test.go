@@ -0,0 +1,59 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	envConfig := filepath.Join(src, "env", "env_config")
+	installDir := filepath.Join(src, "env", "install")
+	os.MkdirAll(filepath.Join(envConfig, "dev"), 0755)
+	os.MkdirAll(filepath.Join(envConfig, "staging"), 0755)
+	os.MkdirAll(installDir, 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"),
+		[]byte("name: env\noutfile: env.yaml\nenum:\n  - local\n  - dev\n  - dev\n"), 0644)
+	ioutil.WriteFile(filepath.Join(installDir, "install.yaml"), []byte("inherit: copy\nenum: [ok]\n"), 0644)
+	for _, name := range []string{
+		filepath.Join(envConfig, "dev.yaml"),
+		filepath.Join(envConfig, "dev.json"),
+		filepath.Join(envConfig, "qa.yaml"),
+		filepath.Join(envConfig, "README.md"),
+		filepath.Join(envConfig, "dev", "dev_ok.yaml"),
+		filepath.Join(envConfig, "staging", "staging.yaml"),
+	} {
+		ioutil.WriteFile(name, []byte("{}\n"), 0644)
+	}
+
+	tree, config, err := Load(src, filepath.Join(src, "live"), "")
+	if err != nil {
+		t.Fatalf("Load failed. Error: %v", err)
+	}
+	expected := []Issue{
+		{SeverityError, filepath.Join(src, "env", "env.yaml"), 6, `duplicate enum value "dev", first on line 5`},
+		{SeverityWarning, filepath.Join(envConfig, "README.md"), 0, "not a .yaml, .yml, .json, .hcl fragment and never read"},
+		{SeverityError, filepath.Join(envConfig, "dev.yaml"), 0, "config layer found in more than one format: also found as " + filepath.Join(envConfig, "dev.json")},
+		{SeverityWarning, filepath.Join(envConfig, "dev", "dev_ok.yaml"), 0, "name matches no combination of env and outer dimension values and is never read"},
+		{SeverityWarning, filepath.Join(envConfig, "qa.yaml"), 0, "name matches no combination of env and outer dimension values and is never read"},
+		{SeverityWarning, filepath.Join(envConfig, "staging"), 0, "dir matches no value of env and is never read"},
+		{SeverityWarning, installDir, 0, "no install_config dir, install outfiles only get inherited and root config values"},
+		{SeverityError, filepath.Join(installDir, "install.yaml"), 0, "outfile is empty, the config would be written over the enum dir"},
+		{SeverityError, filepath.Join(installDir, "install.yaml"), 0, `unknown inherit mode "copy"`},
+	}
+	issues := Lint(tree, config)
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %d: %v", len(expected), len(issues), issues)
+	}
+	for i, issue := range issues {
+		if issue != expected[i] {
+			t.Fatalf("Issue %d should be %q, got %q", i, expected[i], issue)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest a build writes in dst
+const ManifestFile = ".terradim.lock.json"
+
+// manifestVersion is the format version of the manifest
+const manifestVersion = 1
+
+// manifestMode is the mode of the manifest file
+const manifestMode = 0644
+
+// Manifest records the paths a build generated under dst and where each
+// came from in the src template tree
+type Manifest struct {
+	Version int                       `json:"version"`
+	Src     string                    `json:"src"`
+	Files   map[string]*ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes a generated path, keyed by its path relative
+// to dst in the manifest. Template and fragment paths are relative to
+// the manifest src. Hash is the sha256 of file contents.
+type ManifestEntry struct {
+	Template  string            `json:"template"`
+	IsDir     bool              `json:"dir,omitempty"`
+	Dims      map[string]string `json:"dims,omitempty"`
+	Fragments []string          `json:"fragments,omitempty"`
+	Hash      string            `json:"hash,omitempty"`
+}
+
+// NewManifest returns a manifest of outputs rendered from src and
+// written to dst
+func NewManifest(outputs *OutputSet, src, dst string) (*Manifest, error) {
+	manifest := &Manifest{
+		Version: manifestVersion,
+		Src:     filepath.ToSlash(src),
+		Files:   map[string]*ManifestEntry{},
+	}
+	for _, path := range outputs.Paths() {
+		output, _ := outputs.Get(path)
+		rel, err := manifestKey(dst, path)
+		if err != nil {
+			return nil, err
+		}
+		entry := &ManifestEntry{
+			Template: relSrcPath(src, output.Src),
+			IsDir:    output.IsDir,
+			Dims:     output.Dims,
+		}
+		for _, fragment := range output.Fragments {
+			entry.Fragments = append(entry.Fragments, relSrcPath(src, fragment))
+		}
+		if output.IsDir == false {
+			entry.Hash = hashData(output.Data)
+		}
+		if len(entry.Dims) == 0 {
+			entry.Dims = nil
+		}
+		manifest.Files[filepath.ToSlash(rel)] = entry
+	}
+	return manifest, nil
+}
+
+// nextManifest returns the manifest a build of outputs from src records
+// in dst over the previous manifest prev. Entries of combinations outside
+// filter were not rendered and are kept.
+func nextManifest(prev *Manifest, outputs *OutputSet, src, dst string, filter *Filter) (*Manifest, error) {
+	manifest, err := NewManifest(outputs, src, dst)
+	if err != nil {
+		return nil, err
+	}
+	for rel, entry := range prev.Files {
+		if _, ok := manifest.Files[rel]; ok || entry == nil {
+			continue
+		}
+		if filter.MatchDims(entry.Dims) == false {
+			manifest.Files[rel] = entry
+		}
+	}
+	return manifest, nil
+}
+
+// manifestKey returns the manifest key of a path under dst. Paths
+// outside of dst are an error, a later build would prune them.
+func manifestKey(dst, path string) (string, error) {
+	rel, err := filepath.Rel(dst, path)
+	if err != nil {
+		return "", err
+	}
+	if isOutside(rel) {
+		return "", pathErrorf(path, ErrBadManifest, "outside of %s", dst)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// isOutside returns true if a path relative to a dir leaves the dir
+func isOutside(rel string) bool {
+	return filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relSrcPath returns path relative to src, or as is when it is outside
+// of src (ex. a root config spec layer)
+func relSrcPath(src, path string) string {
+	rel, err := filepath.Rel(src, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ReadManifest reads the manifest in dst. A dst without a manifest
+// returns an empty manifest, one recording a path outside of dst
+// returns ErrBadManifest.
+func ReadManifest(dst string) (*Manifest, error) {
+	manifest := &Manifest{Version: manifestVersion, Files: map[string]*ManifestEntry{}}
+	data, err := ioutil.ReadFile(filepath.Join(dst, ManifestFile))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, pathErrorf(filepath.Join(dst, ManifestFile), err, "")
+	}
+	for rel := range manifest.Files {
+		if isOutside(filepath.Clean(filepath.FromSlash(rel))) {
+			return nil, pathErrorf(filepath.Join(dst, ManifestFile), ErrBadManifest, "%s is outside of %s", rel, dst)
+		}
+	}
+	return manifest, nil
+}
+
+// Write writes the manifest in dst
+func (m *Manifest) Write(dst string) error {
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = writeFile(filepath.Join(dst, ManifestFile), data, manifestMode)
+	return err
+}
+
+// Marshal returns the manifest file contents
+func (m *Manifest) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// manifestPath returns the path under dst for a manifest key
+func manifestPath(dst, rel string) string {
+	return filepath.Join(dst, filepath.FromSlash(rel))
+}
+
+// entry returns the manifest entry of a path under dst, or nil if the
+// path is not recorded
+func (m *Manifest) entry(dst, path string) *ManifestEntry {
+	rel, err := manifestKey(dst, path)
+	if err != nil {
+		return nil
+	}
+	return m.Files[rel]
+}
+
+// Orphans returns the manifest keys of paths that are no longer outputs
+// and still exist under dst, children before parents. Paths of
+// combinations outside filter were not rendered and are never orphans.
+func (m *Manifest) Orphans(outputs *OutputSet, dst string, filter *Filter) []string {
+	orphans := []string{}
+	for rel, entry := range m.Files {
+		path := manifestPath(dst, rel)
+		if _, ok := outputs.Get(path); ok {
+			continue
+		}
+		if entry != nil && filter.MatchDims(entry.Dims) == false {
+			continue
+		}
+		if _, err := os.Lstat(path); err == nil {
+			orphans = append(orphans, rel)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(orphans)))
+	return orphans
+}
+
+// prune removes an orphaned path under dst. Dirs are only removed once
+// empty so files placed in them by hand are left alone. A path that does
+// not resolve under dst, ex. through a symlinked dir, is never removed.
+func prune(dst, path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return false, err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, filepath.Join(dir, filepath.Base(path)))
+	if err != nil || rel == "." || isOutside(rel) {
+		return false, pathErrorf(path, ErrBadManifest, "not under %s, refusing to delete", dst)
+	}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil || len(entries) > 0 {
+			return false, err
+		}
+	}
+	return true, os.Remove(path)
+}
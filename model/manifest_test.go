@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	ioutil.WriteFile(filepath.Join(dst, "stale.yaml"), []byte("a: 1\n"), 0644)
+
+	outputs := NewOutputSet()
+	outputs.add(&Output{
+		Src:       "src/dim1/dim1.yaml",
+		Dst:       filepath.Join(dst, "env.yaml"),
+		Data:      []byte("a: 1\n"),
+		Dims:      map[string]string{"dim1": "dev"},
+		Fragments: []string{"src/dim1/dim1_config/dev.yaml", "config.yaml:3"},
+	})
+	manifest, err := NewManifest(outputs, "src", dst)
+	if err != nil {
+		t.Fatalf("NewManifest failed. Error: %v", err)
+	}
+	manifest.Files["stale.yaml"] = &ManifestEntry{Template: "stale.yaml"}
+	if err = manifest.Write(dst); err != nil {
+		t.Fatalf("Manifest write failed. Error: %v", err)
+	}
+
+	read, err := ReadManifest(dst)
+	if err != nil {
+		t.Fatalf("ReadManifest failed. Error: %v", err)
+	}
+	entry, ok := read.Files["env.yaml"]
+	if ok == false {
+		t.Fatalf("Manifest should record env.yaml. Manifest: %+v", read)
+	}
+	if entry.Template != "dim1/dim1.yaml" || entry.Dims["dim1"] != "dev" {
+		t.Fatalf("Entry should record template and dims. Entry: %+v", entry)
+	}
+	if entry.Fragments[0] != "dim1/dim1_config/dev.yaml" || entry.Fragments[1] != "config.yaml:3" {
+		t.Fatalf("Entry should record fragments. Entry: %+v", entry)
+	}
+	if entry.Hash != hashData([]byte("a: 1\n")) {
+		t.Fatalf("Entry should record content hash. Entry: %+v", entry)
+	}
+
+	orphans := read.Orphans(outputs, dst, nil)
+	if len(orphans) != 1 || orphans[0] != "stale.yaml" {
+		t.Fatalf("stale.yaml should be the only orphan. Orphans: %v", orphans)
+	}
+}
+
+func TestManifestOutsideDst(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	dst := filepath.Join(tmp, "out")
+	outside := filepath.Join(tmp, "outside")
+	os.MkdirAll(dst, 0755)
+	os.MkdirAll(outside, 0755)
+	ioutil.WriteFile(filepath.Join(outside, "env.yaml"), []byte("a: 1\n"), 0644)
+
+	outputs := NewOutputSet()
+	outputs.add(&Output{Dst: filepath.Join(outside, "env.yaml"), Data: []byte("a: 1\n")})
+	if _, err = NewManifest(outputs, "src", dst); errors.Is(err, ErrBadManifest) == false {
+		t.Fatalf("Output outside of dst should return ErrBadManifest. Error: %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dst, ManifestFile), []byte(`{"files": {"../outside/env.yaml": {}}}`), 0644)
+	if _, err = ReadManifest(dst); errors.Is(err, ErrBadManifest) == false {
+		t.Fatalf("Manifest key outside of dst should return ErrBadManifest. Error: %v", err)
+	}
+
+	os.Symlink(outside, filepath.Join(dst, "link"))
+	if _, err = prune(dst, filepath.Join(dst, "link", "env.yaml")); errors.Is(err, ErrBadManifest) == false {
+		t.Fatalf("Path resolving outside of dst should not be pruned. Error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "env.yaml")); err != nil {
+		t.Fatalf("File outside of dst should be left alone. Error: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package model
+
+// matrixEntry is a combination of dimension values listed under exclude
+// or include in a descriptor, ex. {env: local, install: wv}. Dimensions
+// may be named by dir or by descriptor name.
+type matrixEntry map[string]string
+
+// match returns whether the entry matches the active values of the
+// dimensions it lists that are set, and whether all of them are set
+func (e matrixEntry) match(values map[string]string) (match, complete bool) {
+	complete = true
+	for dim, value := range e {
+		active, ok := values[dim]
+		if ok == false {
+			complete = false
+			continue
+		}
+		if active != value {
+			return false, complete
+		}
+	}
+	return true, complete
+}
+
+// excluded returns true if the combination active in dataMap is left
+// out of the build. Like a matrix in a CI workflow, a combination is
+// left out once an exclude entry of any descriptor matches all of its
+// values, unless an include entry matches the values set so far.
+// Include entries put back combinations excluded by broader entries,
+// ex. exclude {env: local} with include {env: local, install: md}
+// builds local with only the md install.
+func (c *BuildConfig) excluded(dataMap buildData) bool {
+	_, values := c.activeDims(dataMap)
+	excluded := false
+	for _, dim := range c.Dims() {
+		for _, entry := range c.ConfigMap[dim].Config.Exclude {
+			if match, complete := entry.match(values); match && complete {
+				excluded = true
+			}
+		}
+	}
+	if excluded == false {
+		return false
+	}
+	for _, dim := range c.Dims() {
+		for _, entry := range c.ConfigMap[dim].Config.Include {
+			if match, _ := entry.match(values); match {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validateMatrix checks that exclude and include entries only list
+// known dimensions and their values
+func (c *BuildConfig) validateMatrix() error {
+	for _, dim := range c.Dims() {
+		dimConfig := c.ConfigMap[dim]
+		path := c.descriptorPath(dim)
+		for _, list := range []struct {
+			name    string
+			entries []matrixEntry
+		}{{"exclude", dimConfig.Config.Exclude}, {"include", dimConfig.Config.Include}} {
+			for _, entry := range list.entries {
+				if len(entry) == 0 {
+					return pathErrorf(path, ErrBadDescriptor, "empty %s entry", list.name)
+				}
+				for name, value := range entry {
+					entryDim, ok := c.ConfigMap.dimNamed(name)
+					if ok == false {
+						return pathErrorf(path, ErrBadDescriptor, "%s: unknown dimension %q", list.name, name)
+					}
+					if containsString(c.ConfigMap[entryDim].Config.Enum.Names(), value) == false {
+						return pathErrorf(path, ErrBadDescriptor, "%s: %s has no value %q", list.name, name, value)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
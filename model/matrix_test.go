@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatrixExclude(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "env", "install"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"),
+		[]byte("name: env\noutfile: env.yaml\nenum: [local, dev]\n"), 0644)
+	descriptor := filepath.Join(src, "env", "install", "install.yaml")
+	ioutil.WriteFile(descriptor, []byte(`name: install
+outfile: install.yaml
+enum: [md, ok, wv]
+exclude:
+  - {env: local, install: wv}
+  - {env: dev}
+include:
+  - {env: dev, install: ok}
+`), 0644)
+
+	dst := filepath.Join(src, "live")
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	expected := map[string]bool{
+		"local/md": true, "local/ok": true, "local/wv": false,
+		"dev/md": false, "dev/ok": true, "dev/wv": false,
+	}
+	for combination, built := range expected {
+		_, ok := outputs.Get(filepath.Join(dst, combination, "install.yaml"))
+		if ok != built {
+			t.Fatalf("%s should be built: %v", combination, built)
+		}
+	}
+
+	ioutil.WriteFile(descriptor, []byte("outfile: install.yaml\nenum: [md]\nexclude:\n  - {env: staging}\n"), 0644)
+	_, _, err = Create(src, dst, "")
+	if errors.Is(err, ErrBadDescriptor) == false {
+		t.Fatalf("Unknown exclude value should return ErrBadDescriptor. Error: %v", err)
+	}
+}
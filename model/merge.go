@@ -0,0 +1,340 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// configLayer is a config fragment in merge order. Data is YAML unless
+// Format is set.
+type configLayer struct {
+	Source string
+	Format string
+	Data   []byte
+}
+
+// mergeConfig sets how lists are merged, by default and per key path
+// (ex. args.b.d, dots in keys escaped as in args.batch\.size), with one
+// of the list merge strategies
+type mergeConfig struct {
+	Lists string            `yaml:"lists"`
+	Keys  map[string]string `yaml:"keys"`
+}
+
+// List merge strategies
+const (
+	ListReplace = "replace"
+	ListAppend  = "append"
+	ListUnion   = "union"
+)
+
+// Parent config inheritance modes
+const (
+	InheritMerge     = "merge"
+	InheritNamespace = "namespace"
+)
+
+func validInherit(mode string) bool {
+	switch mode {
+	case "", InheritMerge, InheritNamespace:
+		return true
+	}
+	return false
+}
+
+// deleteMarker removes a key set by an earlier layer
+const deleteMarker = "$(delete)"
+
+// mergedConfig is the result of merging config layers along with the
+// layer source that set each key. History, when set, records every
+// value each layer gave a key in merge order.
+type mergedConfig struct {
+	Value   cty.Value
+	Sources map[string]string
+	Layers  []string
+	History map[string][]keySetting
+	// previous holds the values earlier layers gave a key before it was
+	// replaced, in merge order, for self-references
+	previous map[string][]cty.Value
+}
+
+// keySetting is a value a layer set for a key, or its removal with the
+// delete marker
+type keySetting struct {
+	Source  string
+	Value   cty.Value
+	Deleted bool
+}
+
+func readConfigLayers(configPaths []string) ([]configLayer, error) {
+	layers := make([]configLayer, 0, len(configPaths))
+	for _, path := range configPaths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, configLayer{Source: path, Format: formatExts[filepath.Ext(path)], Data: data})
+	}
+	return layers, nil
+}
+
+func decodeLayer(layer configLayer) (cty.Value, error) {
+	var (
+		val cty.Value
+		err error
+	)
+	switch layer.Format {
+	case FormatJSON:
+		val, err = decodeJSON(layer.Data)
+	case FormatHCL:
+		val, err = decodeHCL(layer.Source, layer.Data)
+	default:
+		val, err = decodeYAML(layer.Data)
+	}
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("%s: %s", layer.Source, err)
+	}
+	if val.IsNull() == false && val.Type().IsObjectType() == false && val.Type().IsMapType() == false {
+		return cty.NilVal, fmt.Errorf("%s: config must be a map", layer.Source)
+	}
+	return val, nil
+}
+
+// mergeDimConfigs deep merges layers in order. Maps are merged key by
+// key, lists are merged with the strategy set in config for their key
+// path and any other value replaces the one set by earlier layers.
+func mergeDimConfigs(layers []configLayer, config mergeConfig) (*mergedConfig, error) {
+	merged := newMergedConfig()
+	if err := merged.mergeLayers(layers, config); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func newMergedConfig() *mergedConfig {
+	return &mergedConfig{Value: cty.EmptyObjectVal, Sources: map[string]string{}, previous: map[string][]cty.Value{}}
+}
+
+// mergeLayers deep merges layers over the merged config in order
+func (m *mergedConfig) mergeLayers(layers []configLayer, config mergeConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		val, err := decodeLayer(layer)
+		if err != nil {
+			return err
+		}
+		m.Layers = append(m.Layers, layer.Source)
+		if val.IsNull() {
+			continue
+		}
+		m.Value = m.merge(config, layer.Source, nil, m.Value, val)
+	}
+	return nil
+}
+
+// inherit returns a new merged config holding the values of m, either
+// as is or under the namespace key, for a nested dimension to merge its
+// own layers over
+func (m *mergedConfig) inherit(mode, namespace string) (*mergedConfig, error) {
+	child := newMergedConfig()
+	child.Layers = append(child.Layers, m.Layers...)
+	switch mode {
+	case InheritMerge:
+		namespace = ""
+		child.Value = m.Value
+	case InheritNamespace:
+		child.Value = cty.ObjectVal(map[string]cty.Value{namespace: m.Value})
+	default:
+		return nil, fmt.Errorf("unknown inherit mode %q", mode)
+	}
+	for key, source := range m.Sources {
+		child.Sources[namespaced(namespace, key)] = source
+	}
+	for key, values := range m.previous {
+		child.previous[namespaced(namespace, key)] = append([]cty.Value(nil), values...)
+	}
+	if m.History != nil {
+		child.History = map[string][]keySetting{}
+		for key, settings := range m.History {
+			child.History[namespaced(namespace, key)] = append([]keySetting(nil), settings...)
+		}
+	}
+	return child, nil
+}
+
+// namespaced returns the key path of key below the namespace key
+func namespaced(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return keyPath([]string{namespace}) + "." + key
+}
+
+func (c mergeConfig) validate() error {
+	strategies := map[string]string{"": c.Lists}
+	for key, strategy := range c.Keys {
+		strategies[key] = strategy
+	}
+	for key, strategy := range strategies {
+		switch strategy {
+		case "", ListReplace, ListAppend, ListUnion:
+		default:
+			return fmt.Errorf("merge: unknown list strategy %q for %q", strategy, key)
+		}
+	}
+	return nil
+}
+
+func (c mergeConfig) listStrategy(path []string) string {
+	if strategy, ok := c.Keys[keyPath(path)]; ok && strategy != "" {
+		return strategy
+	}
+	if c.Lists != "" {
+		return c.Lists
+	}
+	return ListReplace
+}
+
+func isMap(val cty.Value) bool {
+	return val.IsKnown() && val.IsNull() == false &&
+		(val.Type().IsObjectType() || val.Type().IsMapType())
+}
+
+func isList(val cty.Value) bool {
+	return val.IsKnown() && val.IsNull() == false &&
+		(val.Type().IsTupleType() || val.Type().IsListType())
+}
+
+func isDeleteMarker(val cty.Value) bool {
+	return val.IsKnown() && val.IsNull() == false &&
+		val.Type() == cty.String && val.AsString() == deleteMarker
+}
+
+// merge returns src merged over dst at path, recording source for
+// every key src sets
+func (m *mergedConfig) merge(config mergeConfig, source string, path []string, dst, src cty.Value) cty.Value {
+	switch {
+	case isMap(dst) && isMap(src):
+		attrs := dst.AsValueMap()
+		if attrs == nil {
+			attrs = map[string]cty.Value{}
+		}
+		for key, val := range src.AsValueMap() {
+			keyPath := append(path[:len(path):len(path)], key)
+			if isDeleteMarker(val) {
+				delete(attrs, key)
+				m.clearSources(keyPath)
+				m.clearPrevious(keyPath)
+				m.record(keyPath, keySetting{Source: source, Deleted: true})
+				continue
+			}
+			if prev, ok := attrs[key]; ok {
+				attrs[key] = m.merge(config, source, keyPath, prev, val)
+				continue
+			}
+			m.clearSources(keyPath)
+			attrs[key] = m.setSources(source, keyPath, val)
+		}
+		return cty.ObjectVal(attrs)
+	case isList(dst) && isList(src):
+		strategy := config.listStrategy(path)
+		if strategy == ListReplace {
+			break
+		}
+		elems := dst.AsValueSlice()
+		for _, val := range src.AsValueSlice() {
+			if strategy == ListUnion && containsValue(elems, val) {
+				continue
+			}
+			elems = append(elems, val)
+		}
+		m.Sources[keyPath(path)] = source
+		merged := cty.EmptyTupleVal
+		if len(elems) > 0 {
+			merged = cty.TupleVal(elems)
+		}
+		m.record(path, keySetting{Source: source, Value: merged})
+		return merged
+	}
+	if len(path) > 0 && dst.IsNull() == false {
+		m.previous[keyPath(path)] = append(m.previous[keyPath(path)], dst)
+	}
+	m.clearSources(path)
+	return m.setSources(source, path, src)
+}
+
+// setSources records source for path and every map key below it. Keys
+// set to the delete marker have no earlier value and are removed.
+func (m *mergedConfig) setSources(source string, path []string, val cty.Value) cty.Value {
+	m.Sources[keyPath(path)] = source
+	if isMap(val) && val.LengthInt() > 0 {
+		attrs := map[string]cty.Value{}
+		for key, attr := range val.AsValueMap() {
+			keyPath := append(path[:len(path):len(path)], key)
+			if isDeleteMarker(attr) {
+				m.record(keyPath, keySetting{Source: source, Deleted: true})
+				continue
+			}
+			attrs[key] = m.setSources(source, keyPath, attr)
+		}
+		return cty.ObjectVal(attrs)
+	}
+	m.record(path, keySetting{Source: source, Value: val})
+	return val
+}
+
+// record appends a setting to the history of the key at path when
+// history is kept
+func (m *mergedConfig) record(path []string, setting keySetting) {
+	if m.History != nil {
+		key := keyPath(path)
+		m.History[key] = append(m.History[key], setting)
+	}
+}
+
+// clearSources removes the sources recorded for path and below it
+func (m *mergedConfig) clearSources(path []string) {
+	prefix := keyPath(path)
+	for key := range m.Sources {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			delete(m.Sources, key)
+		}
+	}
+}
+
+// clearPrevious removes the earlier values recorded for path and below
+// it, so a key deleted by a layer has none
+func (m *mergedConfig) clearPrevious(path []string) {
+	prefix := keyPath(path)
+	for key := range m.previous {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			delete(m.previous, key)
+		}
+	}
+}
+
+func containsValue(elems []cty.Value, val cty.Value) bool {
+	for _, elem := range elems {
+		if elem.RawEquals(val) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceOf returns the layer source that set the key at path or the
+// nearest of its parents
+func (m *mergedConfig) sourceOf(path []string) string {
+	for i := len(path); i > 0; i-- {
+		if source, ok := m.Sources[keyPath(path[:i])]; ok {
+			return source
+		}
+	}
+	return "<none>"
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func mergeTestConfig(t *testing.T, config mergeConfig, layers ...string) *mergedConfig {
+	configLayers := []configLayer{}
+	for i, data := range layers {
+		configLayers = append(configLayers, configLayer{Source: string(rune('a' + i)), Data: []byte(data)})
+	}
+	merged, err := mergeDimConfigs(configLayers, config)
+	if err != nil {
+		t.Fatalf("Merge failed. Error: %v", err)
+	}
+	return merged
+}
+
+func TestMergeDeep(t *testing.T) {
+	merged := mergeTestConfig(t, mergeConfig{},
+		"args:\n  foo: bar\n  list: [1, 2]\n  gone: true\n",
+		"args:\n  argLevel: 5\n  list: [3]\n  gone: \"$(delete)\"\n")
+	config, _ := encodeDimConfig(merged.Value, FormatYAML)
+	expected := "\"args\":\n  \"argLevel\": 5\n  \"foo\": \"bar\"\n  \"list\":\n  - 3\n"
+	if config != expected {
+		t.Fatalf("Merged config should be %q. Config: %q", expected, config)
+	}
+	if source := merged.Sources["args.foo"]; source != "a" {
+		t.Fatalf("args.foo should come from layer a. Source: %s", source)
+	}
+	if source := merged.Sources["args.argLevel"]; source != "b" {
+		t.Fatalf("args.argLevel should come from layer b. Source: %s", source)
+	}
+	if _, ok := merged.Sources["args.gone"]; ok {
+		t.Fatalf("Deleted key should have no source")
+	}
+}
+
+func TestMergeDeleteFresh(t *testing.T) {
+	merged := mergeTestConfig(t, mergeConfig{},
+		"args:\n  foo: bar\n",
+		"args:\n  extra:\n    keep: 1\n    gone: \"$(delete)\"\nnew:\n  deep:\n    gone: \"$(delete)\"\n")
+	if err := merged.interpolate(map[string]string{}, cty.NilVal); err != nil {
+		t.Fatalf("Interpolate failed. Error: %v", err)
+	}
+	config, _ := encodeDimConfig(merged.Value, FormatYAML)
+	expected := "\"args\":\n  \"extra\":\n    \"keep\": 1\n  \"foo\": \"bar\"\n\"new\":\n  \"deep\": {}\n"
+	if config != expected {
+		t.Fatalf("Delete markers in new maps should be removed, %q. Config: %q", expected, config)
+	}
+}
+
+func TestMergeDottedKeys(t *testing.T) {
+	merged := mergeTestConfig(t, mergeConfig{},
+		"args:\n  batch.size: 100\n  batch:\n    size: 1\n",
+		"args:\n  batch: \"$(delete)\"\n")
+	if source := merged.Sources[`args.batch\.size`]; source != "a" {
+		t.Fatalf("args.batch.size key should keep its source when args.batch is deleted. Sources: %v", merged.Sources)
+	}
+	if _, ok := merged.Sources["args.batch.size"]; ok {
+		t.Fatalf("Deleted args.batch should have no sources below it. Sources: %v", merged.Sources)
+	}
+}
+
+func TestMergeLists(t *testing.T) {
+	config := mergeConfig{Lists: ListUnion, Keys: map[string]string{"b": ListAppend}}
+	merged := mergeTestConfig(t, config, "a: [1, 2]\nb: [1, 2]\n", "a: [2, 3]\nb: [2, 3]\n")
+	out, _ := encodeDimConfig(merged.Value, FormatYAML)
+	if strings.Contains(out, "\"a\":\n- 1\n- 2\n- 3\n") == false {
+		t.Fatalf("a should be unioned. Config: %s", out)
+	}
+	if strings.Contains(out, "\"b\":\n- 1\n- 2\n- 2\n- 3\n") == false {
+		t.Fatalf("b should be appended. Config: %s", out)
+	}
+
+	_, err := mergeDimConfigs(nil, mergeConfig{Lists: "zip"})
+	if err == nil {
+		t.Fatalf("Unknown list strategy should fail")
+	}
+}
+
+func TestMergeInherit(t *testing.T) {
+	parent := mergeTestConfig(t, mergeConfig{}, "accountName: dev\nargs:\n  foo: bar\n")
+
+	child, err := parent.inherit(InheritMerge, "env")
+	if err != nil {
+		t.Fatalf("Inherit failed. Error: %v", err)
+	}
+	child.mergeLayers([]configLayer{{Source: "child", Data: []byte("args:\n  baz: 1\n")}}, mergeConfig{})
+	config, _ := encodeDimConfig(child.Value, FormatYAML)
+	expected := "\"accountName\": \"dev\"\n\"args\":\n  \"baz\": 1\n  \"foo\": \"bar\"\n"
+	if config != expected {
+		t.Fatalf("Inherited config should be %q. Config: %q", expected, config)
+	}
+
+	child, err = parent.inherit(InheritNamespace, "env")
+	if err != nil {
+		t.Fatalf("Inherit failed. Error: %v", err)
+	}
+	if source := child.Sources["env.args.foo"]; source != "a" {
+		t.Fatalf("env.args.foo should come from parent layer a. Source: %s", source)
+	}
+	if _, err = parent.inherit("copy", "env"); err == nil {
+		t.Fatalf("Unknown inherit mode should fail")
+	}
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Output is a file or dir rendered by a build, the template path it
+// came from and the enum combination it was rendered for. Config
+// outfiles come from their dimension descriptor and also list the config
+// fragments merged into them.
+type Output struct {
+	Src       string
+	Dst       string
+	Mode      os.FileMode
+	IsDir     bool
+	IsConfig  bool
+	Data      []byte
+	Dims      map[string]string
+	Fragments []string
+	// merged is the merged config of a config outfile, kept for Explain
+	merged *mergedConfig
+}
+
+// OutputSet holds the outputs of a build keyed by dst path
+type OutputSet struct {
+	mu      sync.Mutex
+	outputs map[string]*Output
+}
+
+// NewOutputSet returns an empty output set
+func NewOutputSet() *OutputSet {
+	return &OutputSet{outputs: map[string]*Output{}}
+}
+
+// add adds an output. Two outputs rendered to the same dst are an error
+// as one would silently replace the other.
+func (s *OutputSet) add(output *Output) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	output.Dst = filepath.Clean(output.Dst)
+	if prev, ok := s.outputs[output.Dst]; ok {
+		return pathErrorf(output.Dst, ErrDuplicateOutput, "rendered from both %s and %s", prev.Src, output.Src)
+	}
+	s.outputs[output.Dst] = output
+	return nil
+}
+
+// Get returns the output for a dst path
+func (s *OutputSet) Get(dst string) (*Output, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	output, ok := s.outputs[filepath.Clean(dst)]
+	return output, ok
+}
+
+// Paths returns the dst paths of all outputs, parents before children
+func (s *OutputSet) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := make([]string, 0, len(s.outputs))
+	for path := range s.outputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Len returns the number of outputs
+func (s *OutputSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.outputs)
+}
+
+// readOutput returns an output for src holding its file contents
+func readOutput(src, dst string) (*Output, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	output := &Output{Src: src, Dst: dst, Mode: info.Mode(), IsDir: info.IsDir()}
+	if output.IsDir == false {
+		if output.Data, err = ioutil.ReadFile(src); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// Apply writes every output to the filesystem. Outputs that are
+// already up to date are skipped so their mtimes are kept.
+func Apply(outputs *OutputSet) error {
+	for _, path := range outputs.Paths() {
+		output, _ := outputs.Get(path)
+		changed, err := writeOutput(output)
+		if err != nil {
+			return err
+		}
+		if changed && viper.GetBool("verbose") == true {
+			if output.IsConfig {
+				fmt.Printf("Write Config: ->  %s\n", output.Dst)
+			} else {
+				fmt.Printf("Write: %s  ->  %s\n", output.Src, output.Dst)
+			}
+		}
+	}
+	return nil
+}
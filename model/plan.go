@@ -0,0 +1,143 @@
+package model
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Planned change actions
+const (
+	ActionCreate = "create"
+	ActionModify = "modify"
+	ActionDelete = "delete"
+)
+
+// Change is a planned change to a path under dst
+type Change struct {
+	Action string
+	Path   string
+	IsDir  bool
+	Diff   string
+}
+
+// Plan compares outputs to the filesystem under dst and returns the
+// changes Write would make, sorted by path. Paths recorded in the dst
+// manifest that are no longer outputs are deleted unless their
+// combination is outside filter, or they are dirs that keep files placed
+// by hand. The manifest Write records for templates under src is a
+// change too. Modified config outfiles carry a unified diff.
+func Plan(outputs *OutputSet, src, dst string, filter *Filter) ([]Change, error) {
+	changes := []Change{}
+	for _, path := range outputs.Paths() {
+		output, _ := outputs.Get(path)
+		change, err := planOutput(output)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	prev, err := ReadManifest(dst)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := nextManifest(prev, outputs, src, dst, filter)
+	if err != nil {
+		return nil, err
+	}
+	deleted := map[string]bool{}
+	for _, rel := range prev.Orphans(outputs, dst, filter) {
+		path := manifestPath(dst, rel)
+		isDir := prev.Files[rel] != nil && prev.Files[rel].IsDir
+		if isDir {
+			kept, err := keepsFiles(path, deleted)
+			if err != nil {
+				return nil, err
+			}
+			if kept {
+				manifest.Files[rel] = prev.Files[rel]
+				continue
+			}
+		}
+		deleted[path] = true
+		changes = append(changes, Change{Action: ActionDelete, Path: path, IsDir: isDir})
+	}
+
+	data, err := manifest.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	change, err := planOutput(&Output{Dst: filepath.Join(dst, ManifestFile), Mode: manifestMode, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		changes = append(changes, *change)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// keepsFiles returns true if the dir at path holds paths that are not
+// deleted, so prune keeps it
+func keepsFiles(path string, deleted map[string]bool) (bool, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if deleted[filepath.Join(path, entry.Name())] == false {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// planOutput returns the change needed to write output, or nil if dst is
+// already up to date
+func planOutput(output *Output) (*Change, error) {
+	info, changed, current, err := outputDrift(output)
+	if err != nil || changed == false {
+		return nil, err
+	}
+	change := &Change{Action: ActionModify, Path: output.Dst, IsDir: output.IsDir}
+	if info == nil {
+		change.Action = ActionCreate
+	} else if output.IsConfig && current != nil && bytes.Equal(current, output.Data) == false {
+		change.Diff = unifiedDiff("a/"+output.Dst, "b/"+output.Dst, current, output.Data)
+	}
+	return change, nil
+}
+
+// outputDrift compares output to its dst by type, permissions and
+// contents, as Write does to decide what to write. It returns the info
+// of dst, nil when dst is missing, whether Write would change dst and
+// the current contents when both are files.
+func outputDrift(output *Output) (os.FileInfo, bool, []byte, error) {
+	info, err := os.Lstat(output.Dst)
+	if os.IsNotExist(err) {
+		return nil, true, nil, nil
+	}
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if info.IsDir() != output.IsDir {
+		return info, true, nil, nil
+	}
+	changed := info.Mode().Perm() != output.Mode.Perm()
+	if output.IsDir {
+		return info, changed, nil, nil
+	}
+	current, err := ioutil.ReadFile(output.Dst)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	return info, changed || bytes.Equal(current, output.Data) == false, current, nil
+}
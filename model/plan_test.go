@@ -0,0 +1,131 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	os.Chmod(dst, 0755)
+	ioutil.WriteFile(filepath.Join(dst, "same.yaml"), []byte("a: 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dst, "env.yaml"), []byte("a: 1\nb: 2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dst, "stale.yaml"), []byte("a: 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dst, "handplaced.yaml"), []byte("a: 1\n"), 0644)
+	prev := &Manifest{Files: map[string]*ManifestEntry{"stale.yaml": {}, "env.yaml": {}}}
+	if err := prev.Write(dst); err != nil {
+		t.Fatalf("Manifest write failed. Error: %v", err)
+	}
+
+	outputs := NewOutputSet()
+	outputs.add(&Output{Dst: dst, Mode: os.ModeDir | 0755, IsDir: true})
+	outputs.add(&Output{Dst: filepath.Join(dst, "same.yaml"), Mode: 0644, Data: []byte("a: 1\n")})
+	outputs.add(&Output{Dst: filepath.Join(dst, "env.yaml"), Mode: 0644, IsConfig: true, Data: []byte("a: 1\nb: 3\n")})
+	outputs.add(&Output{Dst: filepath.Join(dst, "new.yaml"), Mode: 0644, Data: []byte("a: 1\n")})
+
+	changes, err := Plan(outputs, "src", dst, nil)
+	if err != nil {
+		t.Fatalf("Plan failed. Error: %v", err)
+	}
+	expected := map[string]string{
+		ManifestFile: ActionModify,
+		"env.yaml":   ActionModify,
+		"new.yaml":   ActionCreate,
+		"stale.yaml": ActionDelete,
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Plan should have %d changes. Changes: %+v", len(expected), changes)
+	}
+	for _, change := range changes {
+		if action := expected[filepath.Base(change.Path)]; action != change.Action {
+			t.Fatalf("%s should be %s. Change: %+v", change.Path, action, change)
+		}
+	}
+	if changes[1].Diff == "" {
+		t.Fatalf("Modified config should have a diff")
+	}
+
+	os.Remove(filepath.Join(dst, ManifestFile))
+	changes, _ = Plan(outputs, "src", dst, nil)
+	if changes[0].Path != filepath.Join(dst, ManifestFile) || changes[0].Action != ActionCreate {
+		t.Fatalf("Missing manifest should be created. Changes: %+v", changes)
+	}
+}
+
+func TestPlanCheckMode(t *testing.T) {
+	dst, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dst)
+	os.Chmod(dst, 0755)
+	os.Mkdir(filepath.Join(dst, "module"), 0700)
+	ioutil.WriteFile(filepath.Join(dst, "env.yaml"), []byte("a: 1\n"), 0600)
+
+	outputs := NewOutputSet()
+	outputs.add(&Output{Dst: dst, Mode: os.ModeDir | 0755, IsDir: true})
+	outputs.add(&Output{Dst: filepath.Join(dst, "module"), Mode: os.ModeDir | 0755, IsDir: true})
+	outputs.add(&Output{Dst: filepath.Join(dst, "env.yaml"), Mode: 0644, IsConfig: true, Data: []byte("a: 1\n")})
+	manifest, _ := NewManifest(outputs, "src", dst)
+	manifest.Write(dst)
+
+	changes, err := Plan(outputs, "src", dst, nil)
+	if err != nil {
+		t.Fatalf("Plan failed. Error: %v", err)
+	}
+	drift, err := Check(outputs, dst, nil)
+	if err != nil {
+		t.Fatalf("Check failed. Error: %v", err)
+	}
+	if len(changes) != 2 || len(drift) != 2 {
+		t.Fatalf("Plan and check should both report the mode changes. Changes: %+v, Drift: %+v", changes, drift)
+	}
+	for i := range changes {
+		if changes[i].Action != ActionModify || drift[i].Action != DriftModified || changes[i].Path != drift[i].Path {
+			t.Fatalf("%s should be modified. Change: %+v, Drift: %+v", changes[i].Path, changes[i], drift[i])
+		}
+		if changes[i].Diff != "" || drift[i].Diff != "" {
+			t.Fatalf("Mode change should have no diff. Change: %+v, Drift: %+v", changes[i], drift[i])
+		}
+	}
+
+	os.Chmod(filepath.Join(dst, "env.yaml"), 0644)
+	os.Chmod(filepath.Join(dst, "module"), 0755)
+	changes, _ = Plan(outputs, "src", dst, nil)
+	drift, _ = Check(outputs, dst, nil)
+	if len(changes) != 0 || len(drift) != 0 {
+		t.Fatalf("Plan and check should agree dst is up to date. Changes: %+v, Drift: %+v", changes, drift)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff := unifiedDiff("a", "b", []byte("1\n2\n3\n"), []byte("1\n3\n4\n"))
+	expected := "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n 3\n+4\n"
+	if diff != expected {
+		t.Fatalf("Diff should be %q. Diff: %q", expected, diff)
+	}
+	if diff := unifiedDiff("a", "b", []byte("1\n"), []byte("1\n")); diff != "" {
+		t.Fatalf("Equal inputs should have no diff. Diff: %q", diff)
+	}
+	if diff := unifiedDiff("a", "b", []byte("1\x00\n"), []byte("2\n")); diff != "Binary files a and b differ\n" {
+		t.Fatalf("Binary inputs should only be reported as different. Diff: %q", diff)
+	}
+
+	var large, changed strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&large, "%d\n", i)
+		fmt.Fprintf(&changed, "x%d\n", i)
+	}
+	diff = unifiedDiff("a", "b", []byte("head\n"+large.String()), []byte("head\n"+changed.String()))
+	if strings.HasPrefix(diff, "--- a\n+++ b\n@@ -1,20001 +1,20001 @@\n head\n-0\n") == false {
+		t.Fatalf("Large diff should remove all old and add all new lines. Diff: %.80q", diff)
+	}
+}
@@ -0,0 +1,351 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema types
+const (
+	schemaObject  = "object"
+	schemaArray   = "array"
+	schemaString  = "string"
+	schemaNumber  = "number"
+	schemaInteger = "integer"
+	schemaBoolean = "boolean"
+	schemaNull    = "null"
+)
+
+// schema is the subset of JSON Schema merged configs are checked
+// against: type, properties, required, additionalProperties, items,
+// enum, minimum, maximum and pattern. A descriptor sets it with schema:
+// either as the path of a JSON or YAML schema file relative to the
+// descriptor, inline, or as a map of key to type name, ex.
+// {args: {region: string, zones: [string]}}, which allows no other keys.
+type schema struct {
+	Types                []string
+	Properties           map[string]*schema
+	Required             []string
+	AdditionalProperties *schema
+	Items                *schema
+	Enum                 []cty.Value
+	Minimum              *big.Float
+	Maximum              *big.Float
+	Pattern              *regexp.Regexp
+	// never is the schema of additionalProperties: false
+	never bool
+}
+
+// schemaViolation is a merged config value that does not match the
+// schema, at a key path
+type schemaViolation struct {
+	Path []string
+	Msg  string
+}
+
+// loadSchema parses the schema node of a descriptor in dir. A nil
+// schema is returned when none is set.
+func loadSchema(node *yaml.Node, dir string) (*schema, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil, nil
+		}
+		path := node.Value
+		if filepath.IsAbs(path) == false {
+			path = filepath.Join(dir, path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file := &yaml.Node{}
+		if err = yaml.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if len(file.Content) == 0 {
+			return nil, fmt.Errorf("%s: empty schema", path)
+		}
+		s, err := parseSchema(file.Content[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return s, nil
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			switch node.Content[i].Value {
+			case "$schema", "type", "properties":
+				return parseSchema(node)
+			}
+		}
+		return parseShorthandSchema(node)
+	}
+	return nil, fmt.Errorf("line %d: schema must be a path or a map", node.Line)
+}
+
+// parseSchema parses a JSON Schema node
+func parseSchema(node *yaml.Node) (*schema, error) {
+	s := &schema{}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!bool" {
+		s.never = node.Value == "false"
+		return s, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: schema must be a map", node.Line)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		var err error
+		switch key.Value {
+		case "type":
+			if val.Kind == yaml.SequenceNode {
+				err = val.Decode(&s.Types)
+			} else {
+				s.Types = []string{val.Value}
+			}
+			for _, ty := range s.Types {
+				if validSchemaType(ty) == false {
+					return nil, fmt.Errorf("line %d: unknown type %q", val.Line, ty)
+				}
+			}
+		case "properties":
+			if val.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: properties must be a map", val.Line)
+			}
+			s.Properties = map[string]*schema{}
+			for j := 0; j < len(val.Content); j += 2 {
+				if s.Properties[val.Content[j].Value], err = parseSchema(val.Content[j+1]); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			err = val.Decode(&s.Required)
+		case "additionalProperties":
+			s.AdditionalProperties, err = parseSchema(val)
+		case "items":
+			s.Items, err = parseSchema(val)
+		case "enum":
+			if val.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("line %d: enum must be a list", val.Line)
+			}
+			for _, elem := range val.Content {
+				data, err := yaml.Marshal(elem)
+				if err != nil {
+					return nil, err
+				}
+				enumVal, err := decodeYAML(data)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", elem.Line, err)
+				}
+				s.Enum = append(s.Enum, enumVal)
+			}
+		case "minimum":
+			s.Minimum, err = parseSchemaNumber(val)
+		case "maximum":
+			s.Maximum, err = parseSchemaNumber(val)
+		case "pattern":
+			s.Pattern, err = regexp.Compile(val.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %s", val.Line, key.Value, err)
+		}
+	}
+	return s, nil
+}
+
+// parseShorthandSchema parses a map of key to type name, nested map or
+// single item list into an object schema allowing no other keys
+func parseShorthandSchema(node *yaml.Node) (*schema, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if validSchemaType(node.Value) == false {
+			return nil, fmt.Errorf("line %d: unknown type %q", node.Line, node.Value)
+		}
+		return &schema{Types: []string{node.Value}}, nil
+	case yaml.SequenceNode:
+		if len(node.Content) != 1 {
+			return nil, fmt.Errorf("line %d: list type must have one item type", node.Line)
+		}
+		items, err := parseShorthandSchema(node.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Types: []string{schemaArray}, Items: items}, nil
+	case yaml.MappingNode:
+		s := &schema{
+			Types:                []string{schemaObject},
+			Properties:           map[string]*schema{},
+			AdditionalProperties: &schema{never: true},
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			prop, err := parseShorthandSchema(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[node.Content[i].Value] = prop
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("line %d: schema must be a type name, list or map", node.Line)
+}
+
+func parseSchemaNumber(node *yaml.Node) (*big.Float, error) {
+	num, ok := new(big.Float).SetString(node.Value)
+	if ok == false {
+		return nil, fmt.Errorf("%q is not a number", node.Value)
+	}
+	return num, nil
+}
+
+func validSchemaType(ty string) bool {
+	switch ty {
+	case schemaObject, schemaArray, schemaString, schemaNumber, schemaInteger, schemaBoolean, schemaNull:
+		return true
+	}
+	return false
+}
+
+// schemaType returns the schema type of a config value
+func schemaType(val cty.Value) string {
+	ty := val.Type()
+	switch {
+	case val.IsNull():
+		return schemaNull
+	case ty.IsObjectType() || ty.IsMapType():
+		return schemaObject
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		return schemaArray
+	case ty == cty.String:
+		return schemaString
+	case ty == cty.Bool:
+		return schemaBoolean
+	case ty == cty.Number:
+		if val.AsBigFloat().IsInt() {
+			return schemaInteger
+		}
+		return schemaNumber
+	}
+	return ty.FriendlyName()
+}
+
+// validate returns every violation of the schema in val, sorted by key
+// path
+func (s *schema) validate(val cty.Value) []schemaViolation {
+	violations := s.check(nil, val, nil)
+	sort.SliceStable(violations, func(i, j int) bool {
+		return keyPath(violations[i].Path) < keyPath(violations[j].Path)
+	})
+	return violations
+}
+
+func (s *schema) check(path []string, val cty.Value, violations []schemaViolation) []schemaViolation {
+	violate := func(format string, args ...interface{}) []schemaViolation {
+		return append(violations, schemaViolation{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+	if s.never {
+		return violate("key is not allowed")
+	}
+	if val.IsKnown() == false {
+		return violations
+	}
+	ty := schemaType(val)
+	if len(s.Types) > 0 {
+		ok := false
+		for _, allowed := range s.Types {
+			if allowed == ty || (allowed == schemaNumber && ty == schemaInteger) {
+				ok = true
+			}
+		}
+		if ok == false {
+			return violate("must be %s, not %s", strings.Join(s.Types, " or "), ty)
+		}
+	}
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, allowed := range s.Enum {
+			if val.Type().Equals(allowed.Type()) && val.RawEquals(allowed) {
+				ok = true
+			}
+		}
+		if ok == false {
+			return violate("%s is not an allowed value", formatValue(val))
+		}
+	}
+
+	switch ty {
+	case schemaObject:
+		attrs := val.AsValueMap()
+		for _, key := range s.Required {
+			if _, ok := attrs[key]; ok == false {
+				violations = append(violations, schemaViolation{
+					Path: append(path[:len(path):len(path)], key),
+					Msg:  "required key is missing",
+				})
+			}
+		}
+		for _, key := range sortedKeys(attrs) {
+			keyPath := append(path[:len(path):len(path)], key)
+			if prop, ok := s.Properties[key]; ok {
+				violations = prop.check(keyPath, attrs[key], violations)
+			} else if s.AdditionalProperties != nil {
+				violations = s.AdditionalProperties.check(keyPath, attrs[key], violations)
+			}
+		}
+	case schemaArray:
+		if s.Items != nil {
+			for i, elem := range val.AsValueSlice() {
+				violations = s.Items.check(append(path[:len(path):len(path)], fmt.Sprint(i)), elem, violations)
+			}
+		}
+	case schemaString:
+		if s.Pattern != nil && s.Pattern.MatchString(val.AsString()) == false {
+			return violate("%q does not match %s", val.AsString(), s.Pattern)
+		}
+	case schemaInteger, schemaNumber:
+		num := val.AsBigFloat()
+		if s.Minimum != nil && num.Cmp(s.Minimum) < 0 {
+			return violate("%s is less than %s", num.Text('f', -1), s.Minimum.Text('f', -1))
+		}
+		if s.Maximum != nil && num.Cmp(s.Maximum) > 0 {
+			return violate("%s is greater than %s", num.Text('f', -1), s.Maximum.Text('f', -1))
+		}
+	}
+	return violations
+}
+
+func formatValue(val cty.Value) string {
+	switch schemaType(val) {
+	case schemaString:
+		return fmt.Sprintf("%q", val.AsString())
+	case schemaInteger, schemaNumber:
+		return val.AsBigFloat().Text('f', -1)
+	case schemaBoolean:
+		return fmt.Sprint(val.True())
+	}
+	return schemaType(val)
+}
+
+// checkSchema returns an ErrSchema error for each violation of the
+// dimension schema in the merged config, naming the fragment that set
+// the offending key
+func (c *TerradimConfig) checkSchema(merged *mergedConfig) error {
+	if c.schema == nil {
+		return nil
+	}
+	var errs MultiError
+	for _, violation := range c.schema.validate(merged.Value) {
+		source := merged.sourceOf(violation.Path)
+		errs = errs.Append(fmt.Errorf("%s: %w: %s: %s", source, ErrSchema, keyPath(violation.Path), violation.Msg))
+	}
+	return errs.ErrorOrNil()
+}
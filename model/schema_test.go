@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func loadTestSchema(t *testing.T, data, dir string) *schema {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(data), node); err != nil {
+		t.Fatalf("Schema unmarshal failed. Error: %v", err)
+	}
+	s, err := loadSchema(node.Content[0], dir)
+	if err != nil {
+		t.Fatalf("Load schema failed. Error: %v", err)
+	}
+	return s
+}
+
+func TestSchemaValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "install.schema.json"), []byte(`{
+  "type": "object",
+  "required": ["args"],
+  "properties": {
+    "args": {
+      "type": "object",
+      "required": ["region"],
+      "additionalProperties": false,
+      "properties": {
+        "region": {"type": "string", "pattern": "^[a-z]+-[a-z]+-[0-9]$"},
+        "size": {"type": "integer", "minimum": 1},
+        "tier": {"enum": ["small", "large"]}
+      }
+    }
+  }
+}`), 0644)
+	merged := mergeTestConfig(t, mergeConfig{}, "args:\n  regoin: us-east-1\n  size: 0\n  tier: huge\n")
+
+	for _, data := range []string{`install.schema.json`, `{args: {region: string, size: integer, tier: string}}`} {
+		violations := loadTestSchema(t, data, dir).validate(merged.Value)
+		paths := []string{}
+		for _, violation := range violations {
+			paths = append(paths, keyPath(violation.Path))
+		}
+		if strings.Contains(strings.Join(paths, " "), "args.regoin") == false {
+			t.Fatalf("Unknown key should be a violation for %s. Violations: %+v", data, violations)
+		}
+	}
+	violations := loadTestSchema(t, `install.schema.json`, dir).validate(merged.Value)
+	if len(violations) != 4 {
+		t.Fatalf("Schema file should report 4 violations. Violations: %+v", violations)
+	}
+}
+
+func TestRenderSchema(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "env", "env_config"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte(`outfile: env.yaml
+enum: [dev]
+schema:
+  args:
+    region: string
+`), 0644)
+	fragment := filepath.Join(src, "env", "env_config", "dev.yaml")
+	ioutil.WriteFile(fragment, []byte("args:\n  regoin: us-east-1\n"), 0644)
+
+	tree, config, err := Create(src, filepath.Join(src, "live"), "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	_, err = Render(tree, config)
+	if errors.Is(err, ErrSchema) == false {
+		t.Fatalf("Schema violation should return ErrSchema. Error: %v", err)
+	}
+	for _, expected := range []string{"env=dev", fragment, "args.regoin"} {
+		if strings.Contains(err.Error(), expected) == false {
+			t.Fatalf("Error should name %s. Error: %v", expected, err)
+		}
+	}
+}
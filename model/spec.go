@@ -0,0 +1,284 @@
+package model
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// spec is the resolved spec section of the root config. Blocks select
+// the config for each level of dimensions in turn, ex. spec.dev.ok.args
+// applies to dim1=dev, dim2=ok. For the outfile of a combination the
+// layers are applied outermost dimension first, and at every level in
+// the order all -> groups -> enum value, before the config fragments of
+// the tree. Overrides are applied last, after the fragments too, in the
+// order they are listed, to the outfile of the deepest dimension they
+// match on.
+type spec struct {
+	path      string
+	root      *specBlock
+	overrides []specOverride
+}
+
+// specBlock selects the config for one level of dimensions
+type specBlock struct {
+	args   *yaml.Node
+	blocks map[string]*specBlock
+	groups []specGroup
+}
+
+// specGroup applies a block to a list of values per dimension
+type specGroup struct {
+	match map[string][]string
+	block *specBlock
+}
+
+// specOverride applies args to an exact set of dimension values
+type specOverride struct {
+	Dims map[string][]string `yaml:"dims"`
+	Args yaml.Node           `yaml:"args"`
+}
+
+const specAll = "all"
+
+// parseSpec parses the spec section of the root config at path. Keys of
+// a block that are neither all, args, groups nor an enum value of one of
+// dims are args, ex. local: {all: {accountName: dev}}. Groups and
+// overrides must match on dimensions in dims.
+func parseSpec(path string, node *yaml.Node, dims TerradimConfigMap) (*spec, error) {
+	s := &spec{path: path, root: &specBlock{blocks: map[string]*specBlock{}}}
+	if node.Kind == 0 {
+		return s, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, specError(path, node, "spec must be a map")
+	}
+	block := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if key.Value == "overrides" {
+			if val.Kind != yaml.SequenceNode {
+				return nil, specError(path, val, "overrides must be a list")
+			}
+			for _, overrideNode := range val.Content {
+				var override specOverride
+				if err := overrideNode.Decode(&override); err != nil {
+					return nil, specError(path, overrideNode, err.Error())
+				}
+				for name := range override.Dims {
+					if _, ok := dims.dimNamed(name); ok == false {
+						return nil, specError(path, overrideNode, fmt.Sprintf("override matches unknown dimension %q", name))
+					}
+				}
+				s.overrides = append(s.overrides, override)
+			}
+			continue
+		}
+		block.Content = append(block.Content, key, val)
+	}
+	root, err := parseSpecBlock(path, block, dims)
+	if err != nil {
+		return nil, err
+	}
+	s.root = root
+	return s, nil
+}
+
+func parseSpecBlock(path string, node *yaml.Node, dims TerradimConfigMap) (*specBlock, error) {
+	block := &specBlock{blocks: map[string]*specBlock{}}
+	if node.Kind != yaml.MappingNode {
+		return nil, specError(path, node, "spec block must be a map")
+	}
+	args := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		switch {
+		case key.Value == "args":
+			if val.Tag == "!!null" {
+				continue
+			}
+			if val.Kind != yaml.MappingNode {
+				return nil, specError(path, val, "args must be a map")
+			}
+			if len(args.Content) == 0 {
+				args.Line = val.Line
+			}
+			args.Content = append(args.Content, val.Content...)
+		case key.Value == "groups":
+			if val.Kind != yaml.SequenceNode {
+				return nil, specError(path, val, "groups must be a list")
+			}
+			for _, groupNode := range val.Content {
+				group, err := parseSpecGroup(path, groupNode, dims)
+				if err != nil {
+					return nil, err
+				}
+				block.groups = append(block.groups, group)
+			}
+		case key.Value == specAll || dims.hasEnumValue(key.Value):
+			child, err := parseSpecBlock(path, val, dims)
+			if err != nil {
+				return nil, err
+			}
+			block.blocks[key.Value] = child
+		default:
+			if len(args.Content) == 0 {
+				args.Line = key.Line
+			}
+			args.Content = append(args.Content, key, val)
+		}
+	}
+	if len(args.Content) > 0 {
+		block.args = args
+	}
+	return block, nil
+}
+
+// parseSpecGroup splits a group into the dimension values it matches
+// and the block applied to them, ex. {dim2: [ri, wv], args: {...}}
+func parseSpecGroup(path string, node *yaml.Node, dims TerradimConfigMap) (specGroup, error) {
+	group := specGroup{match: map[string][]string{}}
+	if node.Kind != yaml.MappingNode {
+		return group, specError(path, node, "group must be a map")
+	}
+	block := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if val.Kind == yaml.SequenceNode && key.Value != "groups" {
+			if _, ok := dims.dimNamed(key.Value); ok == false {
+				return group, specError(path, key, fmt.Sprintf("group matches unknown dimension %q", key.Value))
+			}
+			var values []string
+			if err := val.Decode(&values); err != nil {
+				return group, specError(path, val, err.Error())
+			}
+			group.match[key.Value] = values
+			continue
+		}
+		block.Content = append(block.Content, key, val)
+	}
+	if len(group.match) == 0 {
+		return group, specError(path, node, "group must list dimension values")
+	}
+	var err error
+	group.block, err = parseSpecBlock(path, block, dims)
+	return group, err
+}
+
+func specError(path string, node *yaml.Node, msg string) error {
+	return fmt.Errorf("%s:%d: %s", path, node.Line, msg)
+}
+
+// matchDims returns true if the active values match every dimension
+// listed in match
+func matchDims(match map[string][]string, values map[string]string) bool {
+	for dim, allowed := range match {
+		value, ok := values[dim]
+		if ok == false {
+			return false
+		}
+		found := false
+		for _, val := range allowed {
+			if val == value {
+				found = true
+				break
+			}
+		}
+		if found == false {
+			return false
+		}
+	}
+	return true
+}
+
+// collect appends the args of blocks selected by values after
+// descending one level per dim
+func (b *specBlock) collect(s *spec, dims []string, values map[string]string, layers []configLayer) ([]configLayer, error) {
+	if len(dims) == 0 {
+		if b.args == nil {
+			return layers, nil
+		}
+		layer, err := s.layer(b.args)
+		if err != nil {
+			return nil, err
+		}
+		return append(layers, layer), nil
+	}
+	var err error
+	dim, rest := dims[0], dims[1:]
+	if block, ok := b.blocks[specAll]; ok {
+		if layers, err = block.collect(s, rest, values, layers); err != nil {
+			return nil, err
+		}
+	}
+	for _, group := range b.groups {
+		if matchDims(group.match, values) {
+			if layers, err = group.block.collect(s, rest, values, layers); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if block, ok := b.blocks[values[dim]]; ok {
+		if layers, err = block.collect(s, rest, values, layers); err != nil {
+			return nil, err
+		}
+	}
+	return layers, nil
+}
+
+func (s *spec) layer(node *yaml.Node) (configLayer, error) {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return configLayer{}, err
+	}
+	return configLayer{
+		Source: fmt.Sprintf("%s:%d", s.path, node.Line),
+		Data:   data,
+	}, nil
+}
+
+// specLayers returns the config layers the root spec resolves for the
+// outfile of dimension key, and the override layers merged after its
+// config fragments
+func (c *BuildConfig) specLayers(key string, dataMap buildData) (layers, overrides []configLayer, err error) {
+	if c.spec == nil {
+		return nil, nil, nil
+	}
+	dims, values := c.activeDims(dataMap)
+	dimOf := map[string]string{}
+	for _, dim := range dims {
+		dimOf[dim] = dim
+		if name := c.ConfigMap[dim].Config.Name; name != "" {
+			dimOf[name] = dim
+		}
+	}
+
+	if layers, err = c.spec.root.collect(c.spec, dims, values, nil); err != nil {
+		return nil, nil, err
+	}
+	for _, override := range c.spec.overrides {
+		deepest := ""
+		for name := range override.Dims {
+			dim, ok := dimOf[name]
+			if ok == false {
+				deepest = ""
+				break
+			}
+			if deepest == "" || c.ConfigMap[dim].Level() > c.ConfigMap[deepest].Level() {
+				deepest = dim
+			}
+		}
+		if deepest != key || matchDims(override.Dims, values) == false {
+			continue
+		}
+		if override.Args.Kind == 0 || override.Args.Tag == "!!null" {
+			continue
+		}
+		layer, err := c.spec.layer(&override.Args)
+		if err != nil {
+			return nil, nil, err
+		}
+		overrides = append(overrides, layer)
+	}
+	return layers, overrides, nil
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+func loadTestSpec(t *testing.T) *BuildConfig {
+	root, err := loadRootConfig("../test/config.yaml")
+	if err != nil {
+		t.Fatalf("Load root config failed. Error: %v", err)
+	}
+	config := &BuildConfig{ConfigMap: TerradimConfigMap{}}
+	for level, dim := range []string{"dim1", "dim2"} {
+		config.ConfigMap[dim] = &TerradimConfig{Depth: level + 1, Config: root.Dims[dim]}
+	}
+	if config.spec, err = parseSpec("config.yaml", &root.Spec, config.ConfigMap); err != nil {
+		t.Fatalf("Parse spec failed. Error: %v", err)
+	}
+	return config
+}
+
+func testSpecLayers(t *testing.T, config *BuildConfig, key string, dataMap buildData) []configLayer {
+	layers, overrides, err := config.specLayers(key, dataMap)
+	if err != nil {
+		t.Fatalf("Spec layers failed. Error: %v", err)
+	}
+	return append(layers, overrides...)
+}
+
+func mergeTestLayers(t *testing.T, layers []configLayer) string {
+	merged, err := mergeDimConfigs(layers, mergeConfig{})
+	if err != nil {
+		t.Fatalf("Merge failed. Error: %v", err)
+	}
+	config, err := encodeDimConfig(merged.Value, FormatYAML)
+	if err != nil {
+		t.Fatalf("Encode failed. Error: %v", err)
+	}
+	return config
+}
+
+func TestSpecLayers(t *testing.T) {
+	config := loadTestSpec(t)
+
+	layers := testSpecLayers(t, config, "dim2", buildData{"dim1": "sand", "dim2": "md"})
+	if len(layers) != 4 {
+		t.Fatalf("Should resolve all, enum all, enum and override layers. Layers: %+v", layers)
+	}
+	merged := mergeTestLayers(t, layers)
+	for _, expected := range []string{`"version": "v2.34.1"`, `"accountName": "sandbox"`, `"foo": "fizz"`} {
+		if strings.Contains(merged, expected) == false {
+			t.Fatalf("Merged config should contain %s. Config: %s", expected, merged)
+		}
+	}
+
+	layers = testSpecLayers(t, config, "dim1", buildData{"dim1": "dev"})
+	if len(layers) != 3 {
+		t.Fatalf("Should resolve all, group and override layers. Layers: %+v", layers)
+	}
+}
+
+func TestSpecOverrideOrder(t *testing.T) {
+	config := loadTestSpec(t)
+
+	layers := testSpecLayers(t, config, "dim2", buildData{"dim1": "dev", "dim2": "ok"})
+	merged := mergeTestLayers(t, layers)
+	if strings.Contains(merged, `"baz": "bar"`) == false {
+		t.Fatalf("Override should win over all args. Config: %s", merged)
+	}
+}
+
+func TestSpecSelfReference(t *testing.T) {
+	config := loadTestSpec(t)
+
+	layers := testSpecLayers(t, config, "dim2", buildData{"dim1": "local", "dim2": "md"})
+	merged, err := mergeDimConfigs(layers, mergeConfig{})
+	if err != nil {
+		t.Fatalf("Merge failed. Error: %v", err)
+	}
+	if err = merged.interpolate(map[string]string{"dim1": "local", "dim2": "md"}, cty.NilVal); err != nil {
+		t.Fatalf("Interpolate failed. Error: %v", err)
+	}
+	if version := merged.Value.GetAttr("version"); version.AsString() != "v1.23.0" {
+		t.Fatalf("version should keep its value from the all block. Version: %#v", version)
+	}
+}
+
+func TestSpecUnknownDims(t *testing.T) {
+	dims := TerradimConfigMap{"dim1": &TerradimConfig{Config: nodeConfig{Name: "env", Enum: enumValues{{Name: "dev"}}}}}
+	for _, spec := range []string{
+		"groups:\n  - dim3: [dev]\n    args: {a: 1}\n",
+		"overrides:\n  - dims: {dim3: [dev]}\n    args: {a: 1}\n",
+	} {
+		node := yaml.Node{}
+		if err := yaml.Unmarshal([]byte(spec), &node); err != nil {
+			t.Fatalf("Unmarshal failed. Error: %v", err)
+		}
+		_, err := parseSpec("config.yaml", node.Content[0], dims)
+		if err == nil || strings.Contains(err.Error(), `unknown dimension "dim3"`) == false {
+			t.Fatalf("Unknown dimension should be an error. Spec: %s Error: %v", spec, err)
+		}
+	}
+}
+
+func TestSpecOverridesFragments(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	configDir := filepath.Join(src, "env", "install", "install_config", "ok")
+	os.MkdirAll(configDir, 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte("name: env\noutfile: env.yaml\nenum: [dev]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "install", "install.yaml"),
+		[]byte("name: install\noutfile: install.yaml\nenum: [ok]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(configDir, "ok_dev.yaml"), []byte("foo: fragment\nbar: fragment\n"), 0644)
+	rootConfig, err := ioutil.TempFile("", "terradim*.yaml")
+	if err != nil {
+		t.Fatalf("TempFile failed. Error: %v", err)
+	}
+	defer os.Remove(rootConfig.Name())
+	ioutil.WriteFile(rootConfig.Name(), []byte(`spec:
+  dev:
+    ok:
+      bar: spec
+  overrides:
+    - dims: {env: [dev], install: [ok]}
+      args: {foo: override}
+`), 0644)
+	dst := filepath.Join(src, "live")
+	tree, config, err := Create(src, dst, rootConfig.Name())
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	output, _ := outputs.Get(filepath.Join(dst, "dev", "ok", "install.yaml"))
+	for _, expected := range []string{`"foo": "override"`, `"bar": "fragment"`} {
+		if output == nil || strings.Contains(string(output.Data), expected) == false {
+			t.Fatalf("Config should contain %s. Output: %+v", expected, output)
+		}
+	}
+}
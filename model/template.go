@@ -0,0 +1,123 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// templateExt marks a template file. It is rendered for every
+// combination and written without the suffix.
+const templateExt = ".tmpl"
+
+// templateMarkerExts are extensions of files that are rendered when they
+// contain template markers, and written under their own name
+var templateMarkerExts = map[string]bool{".hcl": true, ".tf": true}
+
+// isTemplate returns whether the file at src with data is a template,
+// and the dst name suffix to remove when it is
+func isTemplate(src string, data []byte) (bool, string) {
+	if strings.HasSuffix(src, templateExt) {
+		return true, templateExt
+	}
+	if templateMarkerExts[filepath.Ext(src)] && bytes.Contains(data, []byte("{{")) {
+		return true, ""
+	}
+	return false, ""
+}
+
+// renderTemplate renders a template file with the resolved config of
+// the deepest active dimension, ex. {{ .args.region }}, and the active
+// enum values by dimension and descriptor name under .dims, ex.
+// {{ .dims.env }}. Enum values are also set at the top level, ex.
+// {{ .env }}, unless the config has a key of the same name, as a
+// namespace inherited from an outer dimension does. Keys missing from
+// the data are errors.
+func renderTemplate(src string, data []byte, dataMap buildData) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(src)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", src, err)
+	}
+	tmplData, err := templateData(dataMap)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", src, err)
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, tmplData); err != nil {
+		return nil, fmt.Errorf("%s: %s", src, err)
+	}
+	return out.Bytes(), nil
+}
+
+// templateDims is the key of the enum values in template data
+const templateDims = "dims"
+
+// templateData returns the data templates are rendered with
+func templateData(dataMap buildData) (map[string]interface{}, error) {
+	buildConfig := dataMap["buildConfig"].(*BuildConfig)
+	dims, values := buildConfig.activeDims(dataMap)
+	data := map[string]interface{}{}
+	dimConfigs, _ := dataMap["dimConfigs"].(map[string]*mergedConfig)
+	for i := len(dims) - 1; i >= 0; i-- {
+		if merged, ok := dimConfigs[dims[i]]; ok {
+			if config, ok := ctyToGo(merged.Value).(map[string]interface{}); ok {
+				data = config
+			}
+			break
+		}
+	}
+	if _, ok := data[templateDims]; ok {
+		return nil, fmt.Errorf("config key %s is reserved for the enum values in templates", templateDims)
+	}
+	dimValues := map[string]interface{}{}
+	for key, value := range values {
+		dimValues[key] = value
+		if _, ok := data[key]; ok == false {
+			data[key] = value
+		}
+	}
+	data[templateDims] = dimValues
+	return data, nil
+}
+
+// ctyToGo converts a config value to plain Go values for templates.
+// Whole numbers become int64 so they render without a decimal point.
+func ctyToGo(val cty.Value) interface{} {
+	if val.IsNull() || val.IsKnown() == false {
+		return nil
+	}
+	ty := val.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		attrs := map[string]interface{}{}
+		for key, attr := range val.AsValueMap() {
+			attrs[key] = ctyToGo(attr)
+		}
+		return attrs
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		elems := []interface{}{}
+		for _, elem := range val.AsValueSlice() {
+			elems = append(elems, ctyToGo(elem))
+		}
+		return elems
+	case ty == cty.String:
+		return val.AsString()
+	case ty == cty.Bool:
+		return val.True()
+	case ty == cty.Number:
+		num := val.AsBigFloat()
+		if num.IsInt() {
+			if i, acc := num.Int64(); acc == big.Exact {
+				return i
+			}
+		}
+		f, _ := num.Float64()
+		return f
+	}
+	return nil
+}
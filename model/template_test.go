@@ -0,0 +1,108 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "env", "env_config"), 0755)
+	os.MkdirAll(filepath.Join(src, "env", "module"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"),
+		[]byte("name: env\noutfile: env.yaml\nenum: [dev]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "env_config", "dev.yaml"),
+		[]byte("args:\n  region: us-east-1\n  size: 3\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "terragrunt.hcl.tmpl"),
+		[]byte("env = \"{{ .env }}\"\nregion = \"{{ .args.region }}\"\nsize = {{ .args.size }}\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "main.tf"),
+		[]byte("locals { dim = \"{{ .env }}\" }\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "plain.hcl"),
+		[]byte("name = \"${var.name}\"\n"), 0644)
+
+	dst := filepath.Join(src, "live")
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	expected := map[string]string{
+		"terragrunt.hcl": "env = \"dev\"\nregion = \"us-east-1\"\nsize = 3\n",
+		"main.tf":        "locals { dim = \"dev\" }\n",
+		"plain.hcl":      "name = \"${var.name}\"\n",
+	}
+	for name, data := range expected {
+		output, ok := outputs.Get(filepath.Join(dst, "dev", "module", name))
+		if ok == false || string(output.Data) != data {
+			t.Fatalf("%s should be %q. Output: %+v", name, data, output)
+		}
+	}
+	if _, ok := outputs.Get(filepath.Join(dst, "dev", "module", "terragrunt.hcl.tmpl")); ok {
+		t.Fatalf("Template suffix should be removed")
+	}
+
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "terragrunt.hcl.tmpl"),
+		[]byte("region = \"{{ .args.regoin }}\"\n"), 0644)
+	tree, config, _ = Create(src, dst, "")
+	if _, err = Render(tree, config); err == nil || strings.Contains(err.Error(), "regoin") == false {
+		t.Fatalf("Missing template key should be an error. Error: %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "terragrunt.hcl.tmpl"), []byte("env = \"{{ .env }}\"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "module", "terragrunt.hcl"), []byte("env = \"dev\"\n"), 0644)
+	tree, config, _ = Create(src, dst, "")
+	if _, err = Render(tree, config); errors.Is(err, ErrDuplicateOutput) == false {
+		t.Fatalf("Template next to its rendered name should return ErrDuplicateOutput. Error: %v", err)
+	}
+}
+
+func TestRenderTemplateNamespace(t *testing.T) {
+	src, err := ioutil.TempDir("", "terradim")
+	if err != nil {
+		t.Fatalf("TempDir failed. Error: %v", err)
+	}
+	defer os.RemoveAll(src)
+	installDir := filepath.Join(src, "env", "install")
+	os.MkdirAll(filepath.Join(src, "env", "env_config"), 0755)
+	os.MkdirAll(filepath.Join(installDir, "install_config"), 0755)
+	os.MkdirAll(filepath.Join(installDir, "module"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "env", "env.yaml"), []byte("name: env\noutfile: env.yaml\nenum: [dev]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "env", "env_config", "dev.yaml"), []byte("args:\n  region: us-east-1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(installDir, "install.yaml"),
+		[]byte("name: install\noutfile: install.yaml\ninherit: namespace\nenum: [ok]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(installDir, "install_config", "ok.yaml"), []byte("args:\n  size: 3\n"), 0644)
+	ioutil.WriteFile(filepath.Join(installDir, "module", "terragrunt.hcl.tmpl"),
+		[]byte("region = \"{{ .env.args.region }}\"\nenv = \"{{ .dims.env }}\"\ninstall = \"{{ .install }}\"\n"), 0644)
+
+	dst := filepath.Join(src, "live")
+	tree, config, err := Create(src, dst, "")
+	if err != nil {
+		t.Fatalf("Create failed. Error: %v", err)
+	}
+	outputs, err := Render(tree, config)
+	if err != nil {
+		t.Fatalf("Render failed. Error: %v", err)
+	}
+	expected := "region = \"us-east-1\"\nenv = \"dev\"\ninstall = \"ok\"\n"
+	output, ok := outputs.Get(filepath.Join(dst, "dev", "ok", "module", "terragrunt.hcl"))
+	if ok == false || string(output.Data) != expected {
+		t.Fatalf("Namespaced config should not be replaced by the enum value, %q. Output: %+v", expected, output)
+	}
+
+	ioutil.WriteFile(filepath.Join(installDir, "install_config", "ok.yaml"), []byte("dims: [a]\n"), 0644)
+	tree, config, _ = Create(src, dst, "")
+	if _, err = Render(tree, config); err == nil || strings.Contains(err.Error(), "reserved") == false {
+		t.Fatalf("Config key dims should be an error in templates. Error: %v", err)
+	}
+}
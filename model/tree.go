@@ -274,7 +274,26 @@ func (t *Tree) Find(path string) (*Node, bool) {
 	return findFromNode(t.Root(), path)
 }
 
-// WalkSubtree visits children of a node and runs WalkFunc
+// findNearest returns the node at path, or the nearest node above it
+// when path is not in the tree
+func (t *Tree) findNearest(path string) *Node {
+	sep := t.separator
+	path = removeEndSeparators(path, sep)
+	for path != "" {
+		if node, ok := t.Find(path); ok && node.IsRoot() == false {
+			return node
+		}
+		idx := strings.LastIndex(path, sep)
+		if idx < 0 {
+			break
+		}
+		path = path[:idx]
+	}
+	return nil
+}
+
+// WalkSubtree visits children of a node and runs WalkFunc, stopping at
+// the first error
 func WalkSubtree(node *Node, walkFn WalkFunc, data interface{}) error {
 	ok, err := walkFn(node, data)
 	if err != nil {
@@ -283,8 +302,28 @@ func WalkSubtree(node *Node, walkFn WalkFunc, data interface{}) error {
 	if ok {
 		children := node.Children()
 		for _, child := range children {
-			WalkSubtree(child, walkFn, data)
+			if err := WalkSubtree(child, walkFn, data); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
+
+// WalkSubtreeAll visits children of a node and runs WalkFunc like
+// WalkSubtree, but walks past failed nodes and returns every error as
+// a MultiError. Children of a failed node are not visited.
+func WalkSubtreeAll(node *Node, walkFn WalkFunc, data interface{}) error {
+	var errs MultiError
+	ok, err := walkFn(node, data)
+	if err != nil {
+		return errs.Append(err).ErrorOrNil()
+	}
+	if ok {
+		children := node.Children()
+		for _, child := range children {
+			errs = errs.Append(WalkSubtreeAll(child, walkFn, data))
+		}
+	}
+	return errs.ErrorOrNil()
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -148,3 +149,26 @@ func TestFind(t *testing.T) {
 		t.Fatalf("Meta value not set to true. Meta: %+v/n", node.meta)
 	}
 }
+
+func TestWalkSubtreeErrors(t *testing.T) {
+	tree := NewTree()
+	_, _ = tree.Insert("foo/bar/bang", true)
+	_, _ = tree.Insert("foo/baz", true)
+	walkFn := func(node *Node, data interface{}) (bool, error) {
+		if node.Key() == "bang" || node.Key() == "baz" {
+			return false, fmt.Errorf("failed %s", node.Key())
+		}
+		return true, nil
+	}
+
+	err := WalkSubtree(tree.Root(), walkFn, nil)
+	if err == nil || err.Error() != "failed bang" {
+		t.Fatalf("Error from nested child should be returned. Error: %v", err)
+	}
+
+	err = WalkSubtreeAll(tree.Root(), walkFn, nil)
+	errs, ok := err.(MultiError)
+	if ok == false || len(errs) != 2 {
+		t.Fatalf("All child errors should be returned. Error: %v", err)
+	}
+}